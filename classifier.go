@@ -1,27 +1,136 @@
 package classifier
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	neturl "net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 type Config struct {
-	CardinalityThreshold float64
-	MinSamples           int
-	MinLearningCount     int
-	MaxValuesPerNode     int  // Max unique values to track per node (0 = unlimited)
-	PruneHighCardinality bool // Collapse high-cardinality children to bound memory
+	CardinalityThreshold      float64
+	MinSamples                int
+	MinLearningCount          int
+	MinDistinctValues         int                 // Distinct values a position needs before it can be a parameter
+	MinChildrenForVariability int                 // Children a position needs before its cardinality is considered (0 = derived from CardinalityThreshold)
+	MaxValuesPerNode          int                 // Max unique values to track per node (0 = unlimited)
+	PruneHighCardinality      bool                // Collapse high-cardinality children to bound memory
+	CollapseThreshold         int                 // Children a node needs before it can collapse (0 = MaxValuesPerNode)
+	MergeStrategy             MergeStrategy       // How Merge resolves collapsed vs structured nodes
+	Clock                     func() time.Time    `json:"-"` // Time source for first/last-seen times (if TimeTracking), HalfLife and ClassifyTimeout
+	IndexFiles                []string            // Trailing filenames folded into their directory
+	EmbeddedDates             bool                // Extract dates embedded in segments like backup-2024-01-15.tar.gz
+	ClassifyTimeout           time.Duration       // Max trie walk time per Classify (0 = no limit)
+	OutputFormat              OutputFormat        // How parameters are rendered in classified paths
+	PreserveHost              bool                // Prepend the host of full URLs to classified paths
+	ClassifyQuery             bool                // Learn and classify query string values per key
+	ImmutableClassify         bool                // Classify never learns; only Learn updates the trie
+	PreserveExtension         bool                // Render filename parameters as {name}.ext instead of {filename}
+	TrailingSlash             TrailingSlashMode   // How /users/123/ relates to /users/123
+	CollapseEmptySegments     bool                // Treat /api//v1 as /api/v1
+	DecodeSegments            bool                // Percent-decode path segments before learning and classifying
+	StripMatrixParams         bool                // Drop ;key=value matrix parameters from path segments
+	MinTokenLength            int                 // Minimum length of a base64/base64url segment detected as {token}
+	MaxDepth                  int                 // Segments processed per URL; the rest becomes one {param} tail (0 = unlimited)
+	MaxNodes                  int                 // Trie size bound enforced by evicting least recently learned subtrees (0 = unlimited)
+	MaxLineLength             int                 // Longest line LearnReader accepts, in bytes (0 or less = 1 MiB)
+	YearAsID                  bool                // Treat years (2000–2099) as numeric IDs instead of static segments
+	TimestampDigitThreshold   int                 // Integers with at least this many digits are {id}, not {timestamp} (0 = off)
+	ParameterNames            map[string]string   // Output labels for parameter types, e.g. "id" -> "integer"
+	DefaultParamName          string              // Type of parameters no detector recognizes
+	ObjectPrefixes            []string            // Prefixes of prefix_alnum IDs recognized besides the Stripe ones
+	WildcardTail              bool                // Collapse variable-length tails under a variable position into {*}
+	HalfLife                  time.Duration       // Halve learned counts every HalfLife, checked on insert (0 = never)
+	TimeTracking              bool                // Record first/last-seen times of learned routes, read from Clock
+	MinSegmentLengthForParam  int                 // Shorter non-numeric segments are never parameters (0 = off)
+	StaticVersionPrefix       bool                // Keep API version segments (v1, v2) literal at variable positions
+	MaxEnumValues             int                 // Positions with at most this many distinct, repeatedly seen literals stay static (0 = off)
+	LocaleAsParam             bool                // Parameterize locale segments (/en-US/docs -> /{locale}/docs) instead of keeping them static
+	ColorDetection            bool                // Detect short hex segments (ff0000, abc) as {color}
+	SampleRetention           int                 // Recent raw values kept per node for Explain and Walk (0 = none)
+	Separator                 string              // Segment delimiter; anything but "/" also drops the leading delimiter from results
+	Detectors                 []ParameterDetector `json:"-"` // Replaces the built-in detectors when non-nil
+	AdditionalDetectors       []ParameterDetector `json:"-"` // Consulted after Detectors or the specific built-ins, before {filename} and {slug}
+	SegmentOverride           SegmentOverrideFunc `json:"-"` // Consulted before the built-in logic for each classified segment
 }
 
+// OutputFormat controls how parameter segments are rendered.
+type OutputFormat int
+
+const (
+	FormatBraces OutputFormat = iota // /users/{id}
+	FormatColon                      // /users/:id (Express style)
+	FormatAngle                      // /users/<id>
+)
+
+// TrailingSlashMode controls how a trailing slash (/users/123/) is treated.
+type TrailingSlashMode int
+
+const (
+	// TrailingSlashStrip drops the trailing slash, so /users/123/ and
+	// /users/123 learn and classify identically.
+	TrailingSlashStrip TrailingSlashMode = iota
+
+	// TrailingSlashPreserve keeps the trailing slash as an empty final
+	// segment, so both forms are learned as distinct paths.
+	TrailingSlashPreserve
+
+	// TrailingSlashRedirect learns both forms as one path but remembers
+	// that it was seen with a trailing slash, and classifies either form
+	// with the slash, like a server redirecting to the canonical URL.
+	TrailingSlashRedirect
+)
+
 func DefaultConfig() *Config {
 	return &Config{
-		CardinalityThreshold: 0.75,
-		MinSamples:           2,
-		MinLearningCount:     0,
-		MaxValuesPerNode:     0, // unlimited by default for backwards compatibility
-		PruneHighCardinality: false,
+		CardinalityThreshold:      0.75,
+		MinSamples:                2,
+		MinLearningCount:          0,
+		MinDistinctValues:         2,
+		MinChildrenForVariability: 0,
+		MaxValuesPerNode:          0, // unlimited by default for backwards compatibility
+		PruneHighCardinality:      false,
+		MergeStrategy:             PreferStructured,
+		Clock:                     time.Now,
+		IndexFiles:                []string{"index.html", "index.htm"},
+		OutputFormat:              FormatBraces,
+		PreserveHost:              false,
+		ClassifyQuery:             false,
+		ImmutableClassify:         false,
+		PreserveExtension:         false,
+		TrailingSlash:             TrailingSlashStrip,
+		CollapseEmptySegments:     true,
+		DecodeSegments:            false,
+		StripMatrixParams:         false,
+		MinTokenLength:            16,
+		MaxDepth:                  0,
+		MaxNodes:                  0,
+		MaxLineLength:             defaultMaxLineLength,
+		YearAsID:                  false,
+		TimestampDigitThreshold:   0,
+		ParameterNames:            nil,
+		DefaultParamName:          "param",
+		WildcardTail:              false,
+		LocaleAsParam:             false,
+		ColorDetection:            false,
+		SampleRetention:           0,
+		MaxEnumValues:             0,
+		TimeTracking:              true,
+		HalfLife:                  0,
+		MinSegmentLengthForParam:  0,
+		StaticVersionPrefix:       false,
+		Separator:                 "/",
 	}
 }
 
@@ -39,6 +148,65 @@ func WithMinSamples(min int) Option {
 	}
 }
 
+// WithAggressiveMode is a preset that parameterizes readily: a cardinality
+// threshold of 0.5, which also lets two distinct values make a position
+// variable, a single sample, and a lone ID-like value is enough. Suited to
+// traffic where almost every varying segment is an ID. Options passed after
+// it override its values.
+func WithAggressiveMode() Option {
+	return func(c *Config) {
+		c.CardinalityThreshold = 0.5
+		c.MinSamples = 1
+		c.MinDistinctValues = 1
+	}
+}
+
+// WithConservativeMode is a preset that keeps segments static unless the
+// evidence is strong: a cardinality threshold of 0.9, at least three
+// distinct values, and five samples. Suited to APIs with many static routes
+// that share a prefix. Options passed after it override its values.
+func WithConservativeMode() Option {
+	return func(c *Config) {
+		c.CardinalityThreshold = 0.9
+		c.MinSamples = 5
+		c.MinDistinctValues = 3
+	}
+}
+
+// WithMinDistinctValues requires at least n distinct values at a position
+// before it can become a parameter, however often it was seen. With the
+// default of 2, a URL learned once and then classified stays static
+// instead of having its ID inferred from a single repeated observation.
+// 1 lets a lone value that looks like an ID become a parameter.
+func WithMinDistinctValues(n int) Option {
+	return func(c *Config) {
+		c.MinDistinctValues = n
+	}
+}
+
+// WithMinChildrenForVariability sets how many distinct children a position
+// needs before its cardinality ratio is considered. By default this is
+// derived from the threshold: 3, or 2 when CardinalityThreshold is below
+// 0.75. n=2 lets two different IDs make a position variable without
+// lowering the threshold. Use 0 for the derived value.
+func WithMinChildrenForVariability(n int) Option {
+	return func(c *Config) {
+		c.MinChildrenForVariability = n
+	}
+}
+
+// minChildrenForVariability returns the children a node needs before its
+// cardinality ratio is considered.
+func (cfg *Config) minChildrenForVariability() int {
+	if cfg.MinChildrenForVariability > 0 {
+		return cfg.MinChildrenForVariability
+	}
+	if cfg.CardinalityThreshold < 0.75 {
+		return 2
+	}
+	return 3
+}
+
 func WithMinLearningCount(count int) Option {
 	return func(c *Config) {
 		c.MinLearningCount = count
@@ -54,6 +222,25 @@ func WithMaxValuesPerNode(max int) Option {
 	}
 }
 
+// WithCollapseThreshold sets how many children a node needs before
+// PruneHighCardinality or Prune may collapse them, independently of how
+// many values MaxValuesPerNode tracks: e.g. track 100 values per node but
+// only collapse at 1000 children. 0 uses MaxValuesPerNode, as before the
+// option existed.
+func WithCollapseThreshold(n int) Option {
+	return func(c *Config) {
+		c.CollapseThreshold = n
+	}
+}
+
+// collapseThreshold returns the fan-out at which a node may collapse.
+func (cfg *Config) collapseThreshold() int {
+	if cfg.CollapseThreshold > 0 {
+		return cfg.CollapseThreshold
+	}
+	return cfg.MaxValuesPerNode
+}
+
 // WithPruneHighCardinality clears the values map once a node is confirmed
 // as high cardinality, saving additional memory. The node retains its
 // totalCount for cardinality estimation.
@@ -63,11 +250,436 @@ func WithPruneHighCardinality(prune bool) Option {
 	}
 }
 
+// WithMergeStrategy controls how Merge resolves a node that is collapsed
+// in one classifier and structured in the other.
+func WithMergeStrategy(strategy MergeStrategy) Option {
+	return func(c *Config) {
+		c.MergeStrategy = strategy
+	}
+}
+
+// WithClock sets the time source for everything time-based: first/last-seen
+// times of learned routes, HalfLife decay and ClassifyTimeout. Mainly useful
+// for tests. Whether first/last-seen times are recorded at all is up to
+// WithTimeTracking, which takes precedence: with tracking off the clock
+// isn't read for them. It is called by concurrent Classify calls, so it must
+// be safe for concurrent use.
+func WithClock(clock func() time.Time) Option {
+	return func(c *Config) {
+		c.Clock = clock
+	}
+}
+
+// WithIndexFiles sets the filenames that are folded into their directory when
+// they appear as the last segment, so /docs/guide/index.html and /docs/guide/
+// learn and classify as the same path. Pass no names to disable folding.
+func WithIndexFiles(names []string) Option {
+	return func(c *Config) {
+		c.IndexFiles = names
+	}
+}
+
+// WithEmbeddedDates enables extraction of dates embedded in a larger segment,
+// so backup-2024-01-15.tar.gz classifies as backup-{date}.tar.gz and
+// report_20240115.pdf as report_{date}.pdf. Both YYYY-MM-DD and YYYYMMDD
+// forms are recognized.
+func WithEmbeddedDates(enabled bool) Option {
+	return func(c *Config) {
+		c.EmbeddedDates = enabled
+	}
+}
+
+// WithClassifyTimeout bounds the time a single Classify spends walking the
+// trie. Once exceeded, the walk is abandoned and the remaining segments are
+// returned as-is, so latency stays bounded even for pathological inputs.
+// Only the walk is timed: waiting for the lock and learning aren't.
+// Timeouts are counted in Stats. Use 0 for no limit.
+func WithClassifyTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.ClassifyTimeout = d
+	}
+}
+
+// WithOutputFormat sets how parameters are rendered in classified paths:
+// FormatBraces ({id}, default), FormatColon (:id) or FormatAngle (<id>).
+// Only the returned strings change; detection is unaffected.
+func WithOutputFormat(format OutputFormat) Option {
+	return func(c *Config) {
+		c.OutputFormat = format
+	}
+}
+
+// WithPreserveHost prepends the host of full URLs (https://host/path) to the
+// classified path, e.g. api.example.com/users/{id}. The scheme and any
+// userinfo are always dropped; a port is kept as part of the host.
+func WithPreserveHost(preserve bool) Option {
+	return func(c *Config) {
+		c.PreserveHost = preserve
+	}
+}
+
+// WithClassifyQuery enables query string handling: the query is split off the
+// path, value cardinality is learned per key, and classified output renders
+// keys sorted with their values either kept or parameterized, e.g.
+// /search?page={id}&q={param}. When disabled, the query stays part of the
+// last path segment.
+func WithClassifyQuery(enabled bool) Option {
+	return func(c *Config) {
+		c.ClassifyQuery = enabled
+	}
+}
+
+// WithImmutableClassify disables learning during Classify, so only Learn
+// changes the trie and every instance serving the same trained model stays
+// identical. MinLearningCount still applies: Classify returns
+// InsufficientDataError until Learn has been given at least MinLearningCount
+// URLs, and since Classify no longer counts towards it, the gate only opens
+// through Learn.
+func WithImmutableClassify(immutable bool) Option {
+	return func(c *Config) {
+		c.ImmutableClassify = immutable
+	}
+}
+
+// WithTrailingSlash sets how trailing slashes are handled. The default,
+// TrailingSlashStrip, treats /users/123/ the same as /users/123.
+func WithTrailingSlash(mode TrailingSlashMode) Option {
+	return func(c *Config) {
+		c.TrailingSlash = mode
+	}
+}
+
+// WithCollapseEmptySegments folds the empty segments produced by doubled
+// slashes (/api//v1///health) so they don't become empty-string nodes in the
+// trie. Enabled by default.
+func WithCollapseEmptySegments(collapse bool) Option {
+	return func(c *Config) {
+		c.CollapseEmptySegments = collapse
+	}
+}
+
+// WithDecodeSegments percent-decodes path segments (caf%C3%A9 → café) so
+// encoding variants of the same value aren't counted as distinct values.
+// Decoding applies to both Learn and Classify, keeping the two consistent;
+// segments with malformed escapes are kept in their raw form. Decoded
+// slashes and separators stay encoded (a%2Fb → a%2Fb, a%2fb → a%2Fb), so
+// a rendered pattern keeps the same path boundaries as the URL.
+func WithDecodeSegments(decode bool) Option {
+	return func(c *Config) {
+		c.DecodeSegments = decode
+	}
+}
+
+// WithStripMatrixParams drops matrix parameters, everything from the first
+// semicolon of a segment on, before learning and classifying, so
+// /products;color=red;size=l/details is treated as /products/details and
+// session IDs like ;jsessionid=... don't become parameters. A segment that
+// is only matrix parameters is dropped like an empty one.
+func WithStripMatrixParams(strip bool) Option {
+	return func(c *Config) {
+		c.StripMatrixParams = strip
+	}
+}
+
+// WithMinTokenLength sets the minimum length of a base64 or base64url
+// segment, such as a pagination cursor, before it is detected as {token}.
+// Shorter values are left to the other detectors so short slugs aren't
+// swept in.
+func WithMinTokenLength(n int) Option {
+	return func(c *Config) {
+		c.MinTokenLength = n
+	}
+}
+
+// WithMaxDepth limits Learn and Classify to the first n segments of a path.
+// Anything deeper is folded into a single trailing segment that always
+// classifies as {param}, bounding both trie depth and per-call cost for
+// pathological URLs. 0 means unlimited.
+func WithMaxDepth(n int) Option {
+	return func(c *Config) {
+		c.MaxDepth = n
+	}
+}
+
+// WithMaxNodes bounds the total number of trie nodes. When an insert pushes
+// the trie past n, the least recently learned subtrees are evicted until it is
+// back under the limit. The classifier keeps working, but evicted branches
+// are forgotten: a rarely seen route has to be relearned, and may classify
+// as static until it is. 0 means unlimited.
+func WithMaxNodes(n int) Option {
+	return func(c *Config) {
+		c.MaxNodes = n
+	}
+}
+
+// defaultMaxLineLength is the longest line LearnReader accepts by default.
+const defaultMaxLineLength = 1 << 20
+
+// WithMaxLineLength sets the longest line, in bytes, that LearnReader will
+// accept. Longer lines make it fail with bufio.ErrTooLong. Values of 0 or
+// less mean the default of 1 MiB.
+func WithMaxLineLength(n int) Option {
+	return func(c *Config) {
+		c.MaxLineLength = n
+	}
+}
+
+// maxLineLength returns the longest line LearnReader accepts.
+func (cfg *Config) maxLineLength() int {
+	if cfg.MaxLineLength <= 0 {
+		return defaultMaxLineLength
+	}
+	return cfg.MaxLineLength
+}
+
+// WithYearAsID treats numbers in 2000–2099 like any other numeric ID, so
+// /archive/2024 counts as parameter-like and is labeled {id}. By default
+// years are excluded from both so they stay static unless the position
+// varies, where they are labeled like other non-ID numbers.
+func WithYearAsID(enabled bool) Option {
+	return func(c *Config) {
+		c.YearAsID = enabled
+	}
+}
+
+// WithTimestampDigitThreshold labels integers of n or more digits {id}
+// instead of {timestamp}. Integers of ten or more digits are taken for Unix
+// timestamps by default, but Snowflake-style IDs (Twitter, Discord) are 17 to
+// 19 digits: with n = 17, second, millisecond and microsecond timestamps
+// stay {timestamp} while those become {id}. 0 disables the boundary.
+func WithTimestampDigitThreshold(n int) Option {
+	return func(c *Config) {
+		c.TimestampDigitThreshold = n
+	}
+}
+
+// WithDefaultParamName names the type of parameters no detector recognizes,
+// {param} by default, e.g. "string" for {string}. Detected types keep their
+// names.
+func WithDefaultParamName(name string) Option {
+	return func(c *Config) {
+		c.DefaultParamName = name
+	}
+}
+
+// WithObjectPrefixes adds prefixes to the Stripe-style object IDs detected
+// as {id}, such as acct for acct_1A2b3C. The built-in prefixes (cus, sub,
+// prod, price, pm, pi, ch, in, tok, src, ba, card) stay recognized. It
+// panics if a prefix is empty or not alphanumeric.
+func WithObjectPrefixes(prefixes ...string) Option {
+	for _, prefix := range prefixes {
+		if !objectPrefixPattern.MatchString(prefix) {
+			panic(fmt.Sprintf("classifier: invalid object prefix %q: must be non-empty and alphanumeric", prefix))
+		}
+	}
+	return func(c *Config) {
+		c.ObjectPrefixes = append(c.ObjectPrefixes, prefixes...)
+	}
+}
+
+// WithParameterNames renames parameter type labels in classified output, e.g.
+// {"id": "integer", "slug": "string"} turns /users/{id} into
+// /users/{integer}. Detection is unaffected and unmapped types pass through.
+func WithParameterNames(names map[string]string) Option {
+	return func(c *Config) {
+		c.ParameterNames = make(map[string]string, len(names))
+		for from, to := range names {
+			c.ParameterNames[from] = to
+		}
+	}
+}
+
+// WithWildcardTail renders the rest of a path as a single {*} catch-all
+// when it starts at a variable position whose children lead to paths of
+// different lengths, e.g. /files/a/b/c/d.txt and /files/x.txt both classify
+// as /files/{*}. Fixed-depth routes, whose children share a common
+// continuation, are classified as before.
+func WithWildcardTail(enabled bool) Option {
+	return func(c *Config) {
+		c.WildcardTail = enabled
+	}
+}
+
+// WithHalfLife decays learned counts automatically so that observations
+// lose half their weight every d (see Decay). Decay is applied during
+// inserts once at least d has passed since the last one, using the
+// configured Clock. 0 disables it.
+func WithHalfLife(d time.Duration) Option {
+	return func(c *Config) {
+		c.HalfLife = d
+	}
+}
+
+// WithTimeTracking controls whether inserts record when each route was first
+// and last seen, as reported by RouteTable and PatternLastSeen. It is on by
+// default; turning it off saves a Clock call per learned URL. It only
+// decides whether times are recorded; when they are, they come from the
+// Clock (see WithClock), which HalfLife and ClassifyTimeout read either way.
+func WithTimeTracking(enabled bool) Option {
+	return func(c *Config) {
+		c.TimeTracking = enabled
+	}
+}
+
+// WithMinSegmentLengthForParam keeps segments shorter than n characters
+// literal, even at variable positions, and stops them counting as evidence
+// of a parameter, so /v/ab/x doesn't become /v/{slug}/x. Numbers are exempt
+// (numeric ID detection already ignores small ones), as are values matching
+// a registered parameter type. 0 disables the check.
+func WithMinSegmentLengthForParam(n int) Option {
+	return func(c *Config) {
+		c.MinSegmentLengthForParam = n
+	}
+}
+
+// WithStaticVersionPrefix keeps API version segments (v followed by digits,
+// as in /v1/users) literal even at variable positions, so clients spread
+// over /v1, /v2 and /v3 don't turn the version into a parameter. They also
+// stop counting as evidence of a parameter.
+func WithStaticVersionPrefix(enabled bool) Option {
+	return func(c *Config) {
+		c.StaticVersionPrefix = enabled
+	}
+}
+
+// WithSeparator splits keys on sep instead of "/", so dot-delimited names
+// like api.v1.users.123.profile classify to api.v1.users.{id}.profile. The
+// trie works the same for any separator; with one other than "/", results
+// have no leading separator. Host, query and route export handling still
+// assume URLs. An empty sep means "/".
+func WithSeparator(sep string) Option {
+	return func(c *Config) {
+		c.Separator = sep
+	}
+}
+
+// separator returns the segment delimiter, defaulting to "/" for configs
+// restored from snapshots that predate Separator.
+func (cfg *Config) separator() string {
+	if cfg.Separator == "" {
+		return "/"
+	}
+	return cfg.Separator
+}
+
+// WithMaxEnumValues keeps enum-like positions literal: a position with at
+// most n distinct values, none of which looks like a parameter and each seen
+// at least MinSamples times, stays static even if it would otherwise count as
+// variable. This keeps /orders/{id}/shipped from becoming /orders/{id}/{slug}.
+// 0 disables the check.
+func WithMaxEnumValues(n int) Option {
+	return func(c *Config) {
+		c.MaxEnumValues = n
+	}
+}
+
+// WithLocaleAsParam parameterizes positions holding locale codes, rendering
+// /en-US/docs and /fr/docs as /{locale}/docs. By default a position whose
+// values are all ISO 639 language codes or lang-REGION locales stays static,
+// however many locales are seen.
+func WithLocaleAsParam(enabled bool) Option {
+	return func(c *Config) {
+		c.LocaleAsParam = enabled
+	}
+}
+
+// WithColorDetection detects hex color codes as {color}: 3 or 6 hex digits,
+// as in CSS (/theme/ff0000/preview -> /theme/{color}/preview). Off by
+// default since short hex IDs and words like "bed" share the shape; even
+// when on, only codes containing a digit count as evidence of a parameter on
+// their own.
+func WithColorDetection(enabled bool) Option {
+	return func(c *Config) {
+		c.ColorDetection = enabled
+	}
+}
+
+// WithSampleRetention keeps the n most recent raw values absorbed by each
+// wildcard node, so Explain and Walk can show examples of what a collapsed
+// position held; other positions are described by their children. Samples
+// are kept apart from the counted values and capped at n per node however
+// many distinct values arrive, in line with MaxValuesPerNode. 0 keeps none.
+func WithSampleRetention(n int) Option {
+	return func(c *Config) {
+		c.SampleRetention = n
+	}
+}
+
+// WithDetectors replaces the built-in parameter detectors with detectors,
+// consulted in order; the first match labels a segment. Types registered
+// with RegisterParameterType still take precedence.
+func WithDetectors(detectors ...ParameterDetector) Option {
+	return func(c *Config) {
+		c.Detectors = append([]ParameterDetector{}, detectors...)
+	}
+}
+
+// WithAdditionalDetectors adds detectors for types the built-ins don't
+// recognize. They run after the built-ins for specific shapes (or those set
+// by WithDetectors) but before the generic {filename} and {slug} fallbacks,
+// which would otherwise claim most values first. Unlike types added with
+// RegisterParameterType, which are matched before any detector, they can't
+// override a built-in type.
+func WithAdditionalDetectors(detectors ...ParameterDetector) Option {
+	return func(c *Config) {
+		c.AdditionalDetectors = append(c.AdditionalDetectors, detectors...)
+	}
+}
+
+// SegmentOverrideFunc decides a path segment from domain knowledge, given
+// the raw segments before it. If force is true, the segment becomes the
+// parameter {label}, or stays literal if label is empty; otherwise the
+// built-in logic decides.
+type SegmentOverrideFunc func(pathPrefix []string, segment string) (label string, force bool)
+
+// WithSegmentOverride installs fn as an escape hatch for segments the
+// heuristics get wrong, such as a closed set of region codes that should stay
+// literal whatever their cardinality. fn is consulted for every segment
+// Classify, Explain and the pattern listings decide, before the built-in
+// logic, and must be safe for concurrent use. Learning is unaffected.
+func WithSegmentOverride(fn SegmentOverrideFunc) Option {
+	return func(c *Config) {
+		c.SegmentOverride = fn
+	}
+}
+
+// WithPreserveExtension keeps the extension of filename parameters literal,
+// so /downloads/report-2024.pdf classifies as /downloads/{name}.pdf rather
+// than /downloads/{filename}.
+func WithPreserveExtension(preserve bool) Option {
+	return func(c *Config) {
+		c.PreserveExtension = preserve
+	}
+}
+
 type Classifier struct {
-	root         *Segment
-	config       *Config
-	mu           sync.RWMutex
-	learnedCount int
+	root          *Segment
+	methods       map[string]*Segment // per-method tries (LearnMethod); method-less URLs use root
+	hosts         *Segment            // reversed host labels of full URLs (ClassifyURL); nil until one is learned
+	config        *Config
+	mu            sync.RWMutex
+	learnedCount  atomic.Int64
+	timeouts      atomic.Int64
+	customTypes   []customParameterType
+	queryKeys     map[string]*Segment // per-key query value stats (ClassifyQuery)
+	detectors     []ParameterDetector // effective detector chain, built from config
+	userDetectors []ParameterDetector // caller-supplied detectors, also used as parameter evidence
+	prefixedID    *regexp.Regexp      // prefix_alnum IDs, built from config (ObjectPrefixes)
+	tick          uint64              // insert counter stamped on traversed nodes (MaxNodes)
+	nodes         int                 // node count, exact after each eviction pass (MaxNodes)
+	lastDecay     time.Time           // when counts were last decayed (HalfLife)
+	observed      map[string]struct{} // patterns already returned by ClassifyObserve
+	frozen        bool                // learning is disabled (Freeze)
+	shape         uint64              // bumped whenever learned URLs may end at other depths (endDepths)
+
+	// patternCount caches Stats.DistinctPatterns, see cachedDistinctPatterns
+	patternCount atomic.Pointer[patternCount]
+}
+
+// customParameterType is a user-registered parameter detector.
+type customParameterType struct {
+	name    string
+	pattern *regexp.Regexp
 }
 
 func NewClassifier(opts ...Option) *Classifier {
@@ -76,67 +688,421 @@ func NewClassifier(opts ...Option) *Classifier {
 		opt(config)
 	}
 
-	return &Classifier{
-		root:   NewSegment(""),
-		config: config,
+	c := &Classifier{
+		root:      NewSegment(""),
+		methods:   make(map[string]*Segment),
+		config:    config,
+		queryKeys: make(map[string]*Segment),
+		nodes:     1,
+	}
+	c.buildDetectors()
+	return c
+}
+
+// Config returns a copy of the active configuration. Changing the copy has
+// no effect on the classifier.
+func (c *Classifier) Config() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return *c.config.clone()
+}
+
+// clone returns a copy of the config that shares no mutable state with it.
+func (cfg *Config) clone() *Config {
+	cp := *cfg
+	cp.IndexFiles = append([]string(nil), cfg.IndexFiles...)
+	if cfg.Detectors != nil {
+		cp.Detectors = append([]ParameterDetector{}, cfg.Detectors...)
+	}
+	cp.AdditionalDetectors = append([]ParameterDetector(nil), cfg.AdditionalDetectors...)
+	cp.ObjectPrefixes = append([]string(nil), cfg.ObjectPrefixes...)
+	if cfg.ParameterNames != nil {
+		cp.ParameterNames = make(map[string]string, len(cfg.ParameterNames))
+		for from, to := range cfg.ParameterNames {
+			cp.ParameterNames[from] = to
+		}
+	}
+	return &cp
+}
+
+// SetCardinalityThreshold changes the cardinality threshold without
+// discarding learned data. The threshold is consulted when classifying, so
+// the change applies to the next Classify. It returns an error unless
+// threshold is in (0, 1].
+func (c *Classifier) SetCardinalityThreshold(threshold float64) error {
+	if !(threshold > 0 && threshold <= 1) {
+		return fmt.Errorf("invalid cardinality threshold %v: must be in (0, 1]", threshold)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.CardinalityThreshold = threshold
+	return nil
+}
+
+// SetMinSamples changes the minimum samples required before a position can
+// be parameterized, without discarding learned data. It returns an error if
+// min is negative.
+func (c *Classifier) SetMinSamples(min int) error {
+	if min < 0 {
+		return fmt.Errorf("invalid min samples %d: must not be negative", min)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config.MinSamples = min
+	return nil
+}
+
+// RegisterParameterType adds a custom parameter type: segments matching
+// pattern normalize to {name}. Custom types are consulted before every
+// detector, built-in or added with WithAdditionalDetectors, in registration
+// order, so when two custom patterns match the same segment the first
+// registered wins. Register types before learning so the
+// trie's collapse decisions see them too.
+func (c *Classifier) RegisterParameterType(name string, pattern *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.customTypes = append(c.customTypes, customParameterType{name: name, pattern: pattern})
+}
+
+// matchCustomType returns the first registered custom type matching value.
+func (c *Classifier) matchCustomType(value string) (string, bool) {
+	for _, ct := range c.customTypes {
+		if ct.pattern.MatchString(value) {
+			return ct.name, true
+		}
 	}
+	return "", false
 }
 
 func (c *Classifier) Learn(urls []string) {
+	_ = c.LearnContext(context.Background(), urls)
+}
+
+// Reset clears all learned state while keeping the configuration and any
+// registered parameter types. Safe to call concurrently with Classify.
+func (c *Classifier) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	for _, url := range urls {
-		c.insert(url)
-		c.learnedCount++
+	c.root = NewSegment("")
+	c.methods = make(map[string]*Segment)
+	c.hosts = nil
+	c.queryKeys = make(map[string]*Segment)
+	c.observed = nil
+	c.learnedCount.Store(0)
+	c.timeouts.Store(0)
+	c.nodes = 1
+	c.lastDecay = time.Time{}
+	c.shape++
+}
+
+// Snapshot returns an independent in-memory copy of the classifier: its
+// trie, configuration, and registered parameter types. The copy can serve
+// reads while the original keeps learning, e.g. to train in the background
+// and atomically swap the result into the serving path. Neither side sees
+// the other's later changes.
+func (c *Classifier) Snapshot() *Classifier {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snap := &Classifier{
+		root:        c.root.clone(),
+		methods:     make(map[string]*Segment, len(c.methods)),
+		config:      c.config.clone(),
+		customTypes: append([]customParameterType(nil), c.customTypes...),
+		queryKeys:   make(map[string]*Segment, len(c.queryKeys)),
+		tick:        c.tick,
+		nodes:       c.nodes,
+		lastDecay:   c.lastDecay,
+		frozen:      c.frozen,
+	}
+	snap.buildDetectors()
+	snap.learnedCount.Store(c.learnedCount.Load())
+	snap.timeouts.Store(c.timeouts.Load())
+	for method, root := range c.methods {
+		snap.methods[method] = root.clone()
+	}
+	if c.hosts != nil {
+		snap.hosts = c.hosts.clone()
+	}
+	for key, seg := range c.queryKeys {
+		snap.queryKeys[key] = seg.clone()
+	}
+	if c.observed != nil {
+		snap.observed = make(map[string]struct{}, len(c.observed))
+		for pattern := range c.observed {
+			snap.observed[pattern] = struct{}{}
+		}
 	}
+	return snap
 }
 
 func (c *Classifier) insert(url string) {
+	c.insertInto(c.root, url)
+}
+
+// insertInto learns url into the trie rooted at root.
+func (c *Classifier) insertInto(root *Segment, url string) {
+	c.insertWeighted(root, url, 1)
+}
+
+// insertWeighted learns url into the trie rooted at root as if it had been
+// inserted weight times.
+func (c *Classifier) insertWeighted(root *Segment, url string, weight int) {
 	if url == "" {
 		return
 	}
 
-	parts := c.splitURL(url)
-	node := c.root
+	parts, slash := c.splitPath(url)
+	c.insertParts(root, parts, slash, weight)
+
+	if c.config.ClassifyQuery {
+		c.learnQuery(url, weight)
+	}
+	if host, _ := splitHost(url); host != "" {
+		c.learnHost(host, weight)
+	}
+}
+
+// insertParts learns the already split segments parts, ending with a
+// trailing slash if slash is set, into the trie rooted at root as if they
+// had been inserted weight times.
+func (c *Classifier) insertParts(root *Segment, parts []string, slash bool, weight int) {
+	c.maybeDecay()
+
+	node := root
+	c.tick++
+	node.lastAccess = c.tick
+
+	// Remember the path so cached merged children can be kept in sync
+	path := make([]*Segment, 1, len(parts)+1)
+	path[0] = node
+	keys := make([]string, 0, len(parts))
+	restructured := false
 
 	for _, part := range parts {
 		var child *Segment
+		key := part
 
 		// If parent is collapsed, route through wildcard child
 		if node.collapsed {
-			if node.children["*"] == nil {
-				node.children["*"] = NewSegment("*")
-			}
-			child = node.children["*"]
-		} else {
-			if node.children[part] == nil {
-				node.children[part] = NewSegment(part)
-			}
-			child = node.children[part]
+			key = "*"
 		}
-
-		child.totalCount++
-
-		// Only track value if below max limit (0 = unlimited)
-		if c.config.MaxValuesPerNode == 0 || len(child.values) < c.config.MaxValuesPerNode {
-			child.values[part]++
-		} else if _, exists := child.values[part]; exists {
-			child.values[part]++
+		if node.children[key] == nil {
+			node.children[key] = NewSegment(key)
+			c.nodes++
 		}
+		child = node.children[key]
+		child.lastAccess = c.tick
+
+		c.trackValue(child, part, weight)
 
 		// Check if we should collapse this node's children (memory optimization)
 		// Only collapse when children look like dynamic parameters (UUIDs, IDs, etc.)
 		// not when they're static path segments like "api", "users", etc.
 		if c.config.PruneHighCardinality && !node.collapsed &&
-			len(node.children) >= c.config.MaxValuesPerNode &&
+			len(node.children) >= c.config.collapseThreshold() &&
 			c.hasHighVariability(node) && c.childrenLookDynamic(node) {
 			c.collapseChildren(node)
+			restructured = true
+
+			// Continue through the wildcard that absorbed this child
+			key = "*"
+			child = node.children[key]
+		}
+
+		node = child
+		path = append(path, node)
+		keys = append(keys, key)
+	}
+
+	var now time.Time
+	if c.config.TimeTracking {
+		now = c.config.Clock()
+	}
+	if !node.isEnd {
+		c.shape++
+	}
+	node.markEnd(now, weight)
+	if slash && c.config.TrailingSlash == TrailingSlashRedirect {
+		node.slashEnd = true
+	}
+	c.syncMerged(path, keys, parts, restructured, weight, false)
+
+	if c.config.MaxNodes > 0 && c.nodes > c.config.MaxNodes {
+		c.evict()
+	}
+}
+
+// trackValue counts weight traversals of seg with the given raw value.
+func (c *Classifier) trackValue(seg *Segment, value string, weight int) {
+	seg.totalCount.add(weight)
+
+	// Only track value if below max limit (0 = unlimited)
+	if c.config.MaxValuesPerNode == 0 || len(seg.values) < c.config.MaxValuesPerNode {
+		seg.addValue(value, weight)
+	} else if _, exists := seg.values[value]; exists {
+		seg.addValue(value, weight)
+	}
+
+	// A literal node only ever sees its own value, so only wildcards and
+	// query keys need samples
+	if n := c.config.SampleRetention; n > 0 && value != seg.value {
+		seg.addSample(value, n)
+	}
+}
+
+// syncMerged keeps the cached merged-children nodes along an insert path
+// consistent with the trie. The merged view of a node only copies stats from
+// its grandchildren and references nodes below them, so an insert can be
+// applied to the cache in place. A collapse rewires children and just drops
+// the caches. With shared set the caller holds only the read lock (see
+// learnFast): counters are still updated in place, but a cache that would
+// need any other change is dropped instead. Views built concurrently are
+// kept out of the cache by mergedChildrenNode.
+func (c *Classifier) syncMerged(path []*Segment, keys, parts []string, restructured bool, weight int, shared bool) {
+	for i, node := range path {
+		virtual := node.merged.Load()
+		if virtual == nil {
+			continue
+		}
+		if restructured {
+			node.merged.Store(nil)
+			continue
+		}
+		if i+2 >= len(path) {
+			// Insert ended at a direct child; grandchildren are untouched
+			if !virtual.slashEnd && path[i+1].slashEnd {
+				if shared {
+					node.merged.Store(nil)
+				} else {
+					virtual.slashEnd = true
+				}
+			}
+			continue
+		}
+
+		merged := virtual.children[keys[i+1]]
+		if merged == nil || shared && !mergedInPlace(merged, path[i+2:], keys[i+2:], parts[i+1]) {
+			node.merged.Store(nil)
+			continue
+		}
+		merged.totalCount.add(weight)
+		merged.addValue(parts[i+1], weight)
+		if !merged.slashEnd && path[i+2].slashEnd {
+			merged.slashEnd = true
+		}
+		merged.merged.Store(nil)
+		virtual.merged.Store(nil)
+		if i+3 < len(path) {
+			switch existing := merged.children[keys[i+2]]; {
+			case existing == nil:
+				merged.children[keys[i+2]] = path[i+3]
+			case existing != path[i+3] && path[i+3].totalCount.load() == weight:
+				// A sibling that may sort first now shares this grandchild;
+				// rebuild so the choice matches mergeChildren's
+				node.merged.Store(nil)
+			}
+		}
+	}
+}
+
+// mergedInPlace reports whether syncMerged can apply an insert to the merged
+// node by updating counters only, for the rest of the insert path below it.
+func mergedInPlace(merged *Segment, path []*Segment, keys []string, value string) bool {
+	if merged.values[value] == nil || !merged.slashEnd && path[0].slashEnd {
+		return false
+	}
+	return len(path) < 2 || merged.children[keys[0]] != nil
+}
+
+// learnFast learns url into root while holding only the read lock, for the
+// common case where learning just bumps counters: every node on the path
+// exists and already tracks its value (or is full, see MaxValuesPerNode),
+// a learned URL already ends there, and nothing would collapse. Options
+// whose bookkeeping changes the trie on every insert (HalfLife, MaxNodes,
+// SampleRetention, ClassifyQuery) and full URLs, whose host is learned too,
+// always need the write lock. It reports false without
+// changing anything if the write lock is needed. Nodes whose merged views
+// may copy the counters it bumps are marked for the duration, so views
+// built meanwhile aren't cached (see mergedChildrenNode).
+// Caller must hold the read lock.
+func (c *Classifier) learnFast(root *Segment, url string) bool {
+	if host, _ := splitHost(url); host != "" {
+		return false
+	}
+	parts, slash := c.splitPath(url)
+	return c.learnFastParts(root, parts, slash)
+}
+
+// learnFastParts is learnFast for already split segments, as insertParts
+// is to insertWeighted. Caller must hold the read lock.
+func (c *Classifier) learnFastParts(root *Segment, parts []string, slash bool) bool {
+	cfg := c.config
+	if cfg.HalfLife > 0 || cfg.MaxNodes > 0 || cfg.SampleRetention > 0 || cfg.ClassifyQuery {
+		return false
+	}
+
+	node := root
+	path := make([]*Segment, 1, len(parts)+1)
+	path[0] = node
+	keys := make([]string, 0, len(parts))
+	values := make([]*counter, 0, len(parts))
+
+	for _, part := range parts {
+		key := part
+		if node.collapsed {
+			key = "*"
+		} else if cfg.PruneHighCardinality && len(node.children) >= cfg.collapseThreshold() {
+			return false // may collapse
+		}
+		child := node.children[key]
+		if child == nil {
+			return false
+		}
+		value := child.values[part]
+		if value == nil && (cfg.MaxValuesPerNode == 0 || len(child.values) < cfg.MaxValuesPerNode) {
+			return false
 		}
 
 		node = child
+		path = append(path, node)
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	if !node.isEnd || slash && cfg.TrailingSlash == TrailingSlashRedirect && !node.slashEnd {
+		return false
+	}
+	var now time.Time
+	if cfg.TimeTracking {
+		// Only the last-seen time can be moved under the read lock
+		now = cfg.Clock()
+		if node.firstSeen.IsZero() || now.Before(node.firstSeen) {
+			return false
+		}
 	}
 
-	node.isEnd = true
+	// A view at path[i] copies counters from path[i+2] down
+	viewed := path[:max(len(path)-2, 0)]
+	for _, seg := range viewed {
+		seg.beginFastLearn()
+	}
+	for i, seg := range path[1:] {
+		seg.totalCount.add(1)
+		if values[i] != nil {
+			values[i].add(1)
+		}
+	}
+	node.endCount.add(1)
+	node.seen(now)
+	c.syncMerged(path, keys, parts, false, 1, true)
+	for _, seg := range viewed {
+		seg.endFastLearn()
+	}
+	return true
 }
 
 // childrenLookDynamic checks if the majority of a node's children
@@ -167,22 +1133,33 @@ func (c *Classifier) collapseChildren(node *Segment) {
 	wildcard := NewSegment("*")
 	wildcard.pruned = true
 
-	// Merge all children's stats and grandchildren into wildcard
-	for _, child := range node.children {
-		wildcard.totalCount += child.totalCount
-		if child.isEnd {
-			wildcard.isEnd = true
+	// Merge all children's stats and grandchildren into wildcard, in key
+	// order so the result doesn't depend on map iteration
+	keys := make([]string, 0, len(node.children))
+	for key := range node.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := node.children[key]
+		wildcard.totalCount.add(child.totalCount.load())
+		wildcard.lastAccess = max(wildcard.lastAccess, child.lastAccess)
+		wildcard.absorbEnd(child)
+		c.absorbValues(wildcard, child)
+		if key == mergedWildcardKey {
+			for _, sample := range child.recentSamples() {
+				wildcard.addSample(sample, c.config.SampleRetention)
+			}
+		} else {
+			wildcard.addSample(key, c.config.SampleRetention)
 		}
-		// Merge grandchildren
+		// Merge grandchildren along with everything below them, so static
+		// tails like /settings/notifications survive the collapse
 		for name, grandchild := range child.children {
 			if wildcard.children[name] == nil {
 				wildcard.children[name] = grandchild
 			} else {
-				// Merge stats
-				wildcard.children[name].totalCount += grandchild.totalCount
-				for v, cnt := range grandchild.values {
-					wildcard.children[name].values[v] += cnt
-				}
+				c.mergeSegment(wildcard.children[name], grandchild)
 			}
 		}
 	}
@@ -190,44 +1167,475 @@ func (c *Classifier) collapseChildren(node *Segment) {
 	// Replace all children with single wildcard
 	node.children = map[string]*Segment{"*": wildcard}
 	node.collapsed = true
+	c.shape++
+}
+
+// absorbValues carries the values seen at child over to the wildcard that
+// replaces it, within MaxValuesPerNode, so route listings can still pick a
+// representative value that classifies like the collapsed segment.
+func (c *Classifier) absorbValues(wildcard, child *Segment) {
+	values := make([]string, 0, len(child.values))
+	for value := range child.values {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		if limit := c.config.MaxValuesPerNode; limit == 0 || len(wildcard.values) < limit || wildcard.values[value] != nil {
+			wildcard.addValue(value, child.values[value].load())
+		}
+	}
+}
+
+func (c *Classifier) Classify(url string) (string, error) {
+	pattern, _, err := c.ClassifyWithConfidence(url)
+	return pattern, err
+}
+
+// ClassifyWithConfidence is Classify that also reports how confident the
+// classification is. Confidence comes from the cardinality and sample counts
+// of the nodes behind each parameterized segment: 1.0 means strongly
+// parameterized, values around 0.5 or below mean a decision made on barely
+// MinSamples of data. The weakest segment determines the result; a path with
+// no parameters has confidence 1.0.
+func (c *Classifier) ClassifyWithConfidence(url string) (pattern string, confidence float64, err error) {
+	pattern, normalized, err := c.learnAndClassify("", url)
+	if err != nil || pattern == "" {
+		return pattern, 0, err
+	}
+	return pattern, pathConfidence(normalized), nil
+}
+
+// ClassifyWithParams is Classify that also returns the values extracted from
+// each parameterized path segment, keyed by type name as rendered in the
+// pattern; repeated types are numbered, e.g. id and id2 for
+// /orgs/{id}/users/{id}. The query string is not included.
+func (c *Classifier) ClassifyWithParams(url string) (pattern string, params map[string]string, err error) {
+	pattern, normalized, err := c.learnAndClassify("", url)
+	if err != nil || pattern == "" {
+		return pattern, nil, err
+	}
+
+	params = make(map[string]string)
+	seen := make(map[string]int)
+	for _, seg := range normalized {
+		if !seg.param {
+			continue
+		}
+		name := seg.value
+		if renamed, ok := c.config.ParameterNames[name]; ok {
+			name = renamed
+		}
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name += strconv.Itoa(n)
+		}
+		// Embedded parameters only capture the part between prefix and suffix
+		params[name] = seg.raw[len(seg.prefix) : len(seg.raw)-len(seg.suffix)]
+	}
+	return pattern, params, nil
+}
+
+// ClassifyObserve is Classify that also reports whether this is the first
+// time ClassifyObserve produced the pattern, e.g. to alert on newly seen
+// endpoints. Patterns from other Classify variants aren't recorded. The set
+// of seen patterns is kept in memory only: Reset clears it and it isn't
+// serialized. No pattern is new while the classifier is still learning.
+func (c *Classifier) ClassifyObserve(url string) (pattern string, isNew bool, err error) {
+	pattern, _, err = c.learnAndClassify("", url)
+	if err != nil || pattern == "" {
+		return pattern, false, err
+	}
+
+	// Known patterns, the common case, only need the read lock
+	c.mu.RLock()
+	_, seen := c.observed[pattern]
+	c.mu.RUnlock()
+	if seen {
+		return pattern, false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, seen := c.observed[pattern]; seen {
+		return pattern, false, nil
+	}
+	if c.observed == nil {
+		c.observed = make(map[string]struct{})
+	}
+	c.observed[pattern] = struct{}{}
+	return pattern, true, nil
+}
+
+// learnAndClassify implements Classify, returning the normalized path
+// segments behind the pattern as well. A non-empty method selects that
+// method's trie (see ClassifyMethod).
+func (c *Classifier) learnAndClassify(method, url string) (string, []normalizedSegment, error) {
+	return c.learnAndClassifyThen(method, url, nil)
+}
+
+// learnAndClassifyThen is learnAndClassify that, if then is non-nil, passes
+// the normalized segments of a successful classification to then while
+// still holding the read lock.
+func (c *Classifier) learnAndClassifyThen(method, url string, then func(normalized []normalizedSegment)) (string, []normalizedSegment, error) {
+	if url == "" {
+		return "", nil, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.config.ImmutableClassify || c.frozen {
+		if count := int(c.learnedCount.Load()); c.config.MinLearningCount > 0 && count < c.config.MinLearningCount {
+			return "", nil, &InsufficientDataError{Count: count, Threshold: c.config.MinLearningCount}
+		}
+		pattern, normalized := c.classify(c.methodRoot(method), url, c.deadline())
+		if then != nil {
+			then(normalized)
+		}
+		return pattern, normalized, nil
+	}
+
+	// Learn during Classify (memory is bounded by PruneHighCardinality).
+	// Relearning a known path only bumps counters, under the read lock; the
+	// write lock is only taken when the trie has to change
+	var count int
+	if c.learnFast(c.methodRoot(method), url) {
+		count = int(c.learnedCount.Add(1))
+	} else {
+		c.mu.RUnlock()
+		c.mu.Lock()
+		if c.frozen { // frozen while the lock was released
+			count = int(c.learnedCount.Load())
+		} else {
+			c.insertInto(c.learnRoot(method), url)
+			count = int(c.learnedCount.Add(1))
+		}
+		c.mu.Unlock()
+		c.mu.RLock()
+	}
+
+	// Return error if still in learning phase
+	if threshold := c.config.MinLearningCount; threshold > 0 && count <= threshold {
+		return "", nil, &InsufficientDataError{Count: count, Threshold: threshold}
+	}
+
+	pattern, normalized := c.classify(c.methodRoot(method), url, c.deadline())
+	if then != nil {
+		then(normalized)
+	}
+	return pattern, normalized, nil
+}
+
+// ClassifyOnly normalizes a URL against the current trie without learning it.
+// It only takes the read lock, never changes LearnedCount and never returns
+// InsufficientDataError, so a training phase can be kept separate from a
+// serving phase.
+func (c *Classifier) ClassifyOnly(url string) (string, error) {
+	if url == "" {
+		return "", nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pattern, _ := c.classify(c.root, url, c.deadline())
+	return pattern, nil
+}
+
+// DryRunClassify reports what url would classify as, without side effects:
+// it never learns, never returns InsufficientDataError and, unlike
+// ClassifyOnly, doesn't count timeouts in Stats either, so probing the model
+// leaves no trace. The walk runs under the read lock alone and is bounded by
+// ClassifyTimeout, so it can back an admin endpoint without holding up
+// learning. Classify, by contrast, learns url first.
+func (c *Classifier) DryRunClassify(url string) (string, error) {
+	if url == "" {
+		return "", nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pattern, _, _ := c.dryRun(c.root, url, c.deadline())
+	return pattern, nil
+}
+
+// deadline returns the time by which the trie walk of a classification
+// starting now must finish, or the zero time if there is no timeout. It is
+// taken once the lock is held, so waiting for the lock, or learning the URL
+// first, doesn't count against ClassifyTimeout. Caller must hold at least
+// the read lock.
+func (c *Classifier) deadline() time.Time {
+	if c.config.ClassifyTimeout <= 0 {
+		return time.Time{}
+	}
+	return c.config.Clock().Add(c.config.ClassifyTimeout)
+}
+
+// classify renders the classification of url against the trie at root and
+// returns the normalized path segments behind it.
+// Caller must hold at least the read lock.
+func (c *Classifier) classify(root *Segment, url string, deadline time.Time) (string, []normalizedSegment) {
+	result, normalized, timedOut := c.dryRun(root, url, deadline)
+	if timedOut {
+		c.timeouts.Add(1)
+	}
+	return result, normalized
+}
+
+// dryRun is classify without recording a timeout, which it reports instead.
+// Caller must hold at least the read lock.
+func (c *Classifier) dryRun(root *Segment, url string, deadline time.Time) (string, []normalizedSegment, bool) {
+	normalized, end, timedOut := c.normalizeFrom(root, c.splitURL(url), deadline)
+
+	result := c.render(normalized)
+	if end != nil && end.slashEnd && len(normalized) > 0 {
+		result += c.config.separator()
+	}
+	if c.config.ClassifyQuery {
+		result += c.renderQuery(c.normalizeQuery(url))
+	}
+	if c.config.PreserveHost {
+		if host, _ := splitHost(url); host != "" {
+			result = host + result
+		}
+	}
+	return result, normalized, timedOut
+}
+
+// normalizedSegment is a single segment of a classified path: either the
+// literal segment or the detected parameter type. A parameter may be embedded
+// in static text, e.g. backup-{date}.tar.gz.
+type normalizedSegment struct {
+	value      string
+	param      bool
+	prefix     string
+	suffix     string
+	confidence float64 // for parameters: how well the trie data supports the decision
+
+	// Decision trace, used by Explain.
+	raw  string
+	rule DecisionRule
+	node *Segment // node whose children decided this segment, nil if none
+}
+
+func literalSegment(value string) normalizedSegment {
+	return normalizedSegment{value: value}
+}
+
+func paramSegment(paramType string) normalizedSegment {
+	return normalizedSegment{value: paramType, param: true}
+}
+
+func (s normalizedSegment) String() string {
+	return s.format(FormatBraces)
+}
+
+func (s normalizedSegment) format(f OutputFormat) string {
+	if !s.param {
+		return s.value
+	}
+	switch f {
+	case FormatColon:
+		return s.prefix + ":" + s.value + s.suffix
+	case FormatAngle:
+		return s.prefix + "<" + s.value + ">" + s.suffix
+	default:
+		return s.prefix + "{" + s.value + "}" + s.suffix
+	}
+}
+
+// paramFor returns the parameter segment for a part at a variable position.
+func (c *Classifier) paramFor(part string) normalizedSegment {
+	if seg, ok := c.embeddedParam(part); ok {
+		return seg
+	}
+
+	paramType := c.classifyParameterType(part)
+	if paramType == "filename" && c.config.PreserveExtension {
+		seg := paramSegment("name")
+		seg.suffix = part[strings.LastIndex(part, "."):]
+		return seg
+	}
+	return paramSegment(paramType)
+}
+
+// paramAt returns the parameter segment for a part below the high-variability
+// node, scored with that node's decision confidence.
+func (c *Classifier) paramAt(node *Segment, part string, rule DecisionRule) normalizedSegment {
+	seg := c.paramFor(part)
+	seg.confidence = c.decisionConfidence(node)
+	seg.raw, seg.rule, seg.node = part, rule, node
+	return seg
+}
+
+// variableSegment is paramAt for a part at a variable position, except that
+// parts too short to be parameters stay literal.
+func (c *Classifier) variableSegment(node *Segment, part string, rule DecisionRule) normalizedSegment {
+	if pinned, ok := c.pinnedLiteral(part); ok {
+		return c.literalFor(node, part, pinned)
+	}
+	return c.paramAt(node, part, rule)
+}
+
+// pinnedLiteral reports whether part stays literal even at a variable
+// position, and the rule keeping it so.
+func (c *Classifier) pinnedLiteral(part string) (DecisionRule, bool) {
+	if c.config.StaticVersionPrefix && versionPattern.MatchString(part) {
+		return RuleVersionPrefix, true
+	}
+	if c.shortSegment(part) {
+		return RuleShortSegment, true
+	}
+	return RuleStatic, false
+}
+
+// shortSegment reports whether part is shorter than MinSegmentLengthForParam
+// and so never a parameter. Numbers are exempt since numeric ID detection
+// has its own ranges, and so are values matching a registered type.
+func (c *Classifier) shortSegment(part string) bool {
+	n := c.config.MinSegmentLengthForParam
+	if n <= 0 || utf8.RuneCountInString(part) >= n {
+		return false
+	}
+	if _, err := strconv.ParseInt(part, 10, 64); err == nil {
+		return false
+	}
+	_, custom := c.matchCustomType(part)
+	return !custom
+}
+
+// literalFor returns the segment for a part at a static position. Embedded
+// dates are still parameterized since they vary by definition.
+func (c *Classifier) literalFor(node *Segment, part string, rule DecisionRule) normalizedSegment {
+	seg, ok := c.embeddedParam(part)
+	if ok {
+		seg.confidence = 1.0 // decided by the pattern alone
+		rule = RuleEmbeddedDate
+	} else {
+		seg = literalSegment(part)
+	}
+	seg.raw, seg.rule, seg.node = part, rule, node
+	return seg
+}
+
+// pathConfidence is the confidence of the weakest parameter decision in a
+// classified path, or 1.0 if nothing was parameterized.
+func pathConfidence(segments []normalizedSegment) float64 {
+	confidence := 1.0
+	for _, seg := range segments {
+		if seg.param && seg.confidence < confidence {
+			confidence = seg.confidence
+		}
+	}
+	return confidence
+}
+
+func renderPath(segments []normalizedSegment) string {
+	return formatPath(segments, FormatBraces)
 }
 
-func (c *Classifier) Classify(url string) (string, error) {
-	if url == "" {
-		return "", nil
+func formatPath(segments []normalizedSegment, f OutputFormat) string {
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		parts[i] = seg.format(f)
 	}
+	return "/" + strings.Join(parts, "/")
+}
 
-	// Always learn during Classify (memory is bounded by PruneHighCardinality)
-	c.mu.Lock()
-	c.insert(url)
-	c.learnedCount++
-	count := c.learnedCount
-	belowMin := c.config.MinLearningCount > 0 && count <= c.config.MinLearningCount
-	c.mu.Unlock()
+// render formats a classified path for callers using the configured
+// OutputFormat and ParameterNames.
+func (c *Classifier) render(segments []normalizedSegment) string {
+	sep := c.config.separator()
+	if len(c.config.ParameterNames) == 0 && sep == "/" {
+		return formatPath(segments, c.config.OutputFormat)
+	}
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		parts[i] = c.formatSegment(seg)
+	}
+	if sep != "/" {
+		return strings.Join(parts, sep)
+	}
+	return "/" + strings.Join(parts, "/")
+}
 
-	// Return error if still in learning phase
-	if belowMin {
-		return "", &InsufficientDataError{Count: count}
+// formatSegment formats a single segment for callers, renaming parameter
+// types according to ParameterNames.
+func (c *Classifier) formatSegment(seg normalizedSegment) string {
+	if seg.param {
+		if name, ok := c.config.ParameterNames[seg.value]; ok {
+			seg.value = name
+		}
 	}
+	return seg.format(c.config.OutputFormat)
+}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// normalize walks the trie along parts and decides for each segment whether
+// it stays literal or becomes a parameter. Caller must hold at least the read lock.
+func (c *Classifier) normalize(parts []string) []normalizedSegment {
+	normalized, _, _ := c.normalizeBefore(parts, time.Time{})
+	return normalized
+}
 
-	parts := c.splitURL(url)
-	if len(parts) == 0 {
-		return "/", nil
+// normalizeBefore is normalize with a deadline checked between segments. If
+// the deadline passes, the remaining parts are appended raw and timedOut is
+// true. A zero deadline disables the check.
+// end is the node the path resolved to, or nil if it left the learned trie.
+func (c *Classifier) normalizeBefore(parts []string, deadline time.Time) (normalized []normalizedSegment, end *Segment, timedOut bool) {
+	return c.normalizeFrom(c.root, parts, deadline)
+}
+
+// normalizeFrom is normalizeBefore against the trie at root.
+func (c *Classifier) normalizeFrom(root *Segment, parts []string, deadline time.Time) (normalized []normalizedSegment, end *Segment, timedOut bool) {
+	n := c.config.MaxDepth
+	if n <= 0 || len(parts) <= n {
+		return c.normalizeSegments(root, parts, deadline)
 	}
 
-	normalized := make([]string, 0, len(parts))
-	node := c.root
+	normalized, _, timedOut = c.normalizeSegments(root, parts[:n], deadline)
+	seg := paramSegment("param")
+	seg.confidence = 1.0
+	seg.raw, seg.rule = parts[n], RuleMaxDepth
+	return append(normalized, seg), nil, timedOut
+}
+
+// normalizeSegments is normalizeFrom without the MaxDepth limit, for host
+// labels, which don't go through splitPath either.
+func (c *Classifier) normalizeSegments(root *Segment, parts []string, deadline time.Time) (normalized []normalizedSegment, end *Segment, timedOut bool) {
+	normalized = make([]normalizedSegment, 0, len(parts)+1)
+	node := root
 
 	for i := 0; i < len(parts); i++ {
 		part := parts[i]
 
+		if !deadline.IsZero() && c.config.Clock().After(deadline) {
+			for j := i; j < len(parts); j++ {
+				seg := literalSegment(parts[j])
+				seg.raw, seg.rule = parts[j], RuleTimeout
+				normalized = append(normalized, seg)
+			}
+			return normalized, nil, true
+		}
+
+		if seg, ok := c.overridden(parts, i); ok {
+			normalized = append(normalized, seg)
+			if node = c.overrideStep(node, part); node == nil {
+				return append(normalized, c.unlearned(parts, i+1)...), nil, false
+			}
+			continue
+		}
+
+		if c.config.WildcardTail && (node.collapsed || c.hasHighVariability(node)) && c.variableDepthTail(node) {
+			seg := paramSegment("*")
+			seg.confidence = c.decisionConfidence(node)
+			seg.raw, seg.rule, seg.node = strings.Join(parts[i:], c.config.separator()), RuleWildcardTail, node
+			return append(normalized, seg), nil, false
+		}
+
 		// Handle collapsed nodes - they are always high variability
 		if node.collapsed {
-			paramType := c.classifyParameterType(part)
-			normalized = append(normalized, "{"+paramType+"}")
+			normalized = append(normalized, c.variableSegment(node, part, RuleCollapsed))
 
 			// Use wildcard child to continue
 			if wildcardChild, exists := node.children["*"]; exists {
@@ -236,69 +1644,196 @@ func (c *Classifier) Classify(url string) (string, error) {
 			continue
 		}
 
+		rule := c.variabilityRule(node)
+
 		if child, exists := node.children[part]; exists {
-			if c.hasHighVariability(node) {
-				paramType := c.classifyParameterType(part)
-				normalized = append(normalized, "{"+paramType+"}")
-
-				commonChildren := c.findCommonChildrenAcrossAllSiblings(node)
-				if len(commonChildren) > 0 {
-					virtualNode := &Segment{
-						value:    "",
-						children: make(map[string]*Segment),
-						isEnd:    false,
-					}
-					for k, v := range commonChildren {
-						virtualNode.children[k] = v
-					}
+			if pinned, ok := c.pinnedLiteral(part); ok && rule != RuleStatic {
+				normalized = append(normalized, c.literalFor(node, part, pinned))
+				node = child
+			} else if rule != RuleStatic {
+				normalized = append(normalized, c.paramAt(node, part, rule))
+
+				if virtualNode := c.commonChildrenNode(node); virtualNode != nil {
 					node = virtualNode
 					continue
 				}
 				node = child
 			} else {
-				normalized = append(normalized, part)
+				normalized = append(normalized, c.literalFor(node, part, RuleStatic))
 				node = child
 			}
 			continue
 		}
 
-		if c.hasHighVariability(node) {
-			paramType := c.classifyParameterType(part)
-			normalized = append(normalized, "{"+paramType+"}")
+		if rule != RuleStatic {
+			normalized = append(normalized, c.variableSegment(node, part, rule))
 
-			commonChildren := c.findCommonChildrenAcrossAllSiblings(node)
-			if len(commonChildren) > 0 {
-				virtualNode := &Segment{
-					value:    "",
-					children: make(map[string]*Segment),
-					isEnd:    false,
-				}
-				for k, v := range commonChildren {
-					virtualNode.children[k] = v
-				}
+			if virtualNode := c.commonChildrenNode(node); virtualNode != nil {
 				node = virtualNode
 				continue
 			}
+			if i == len(parts)-1 {
+				return normalized, c.mergedChildrenNode(node), false
+			}
 
 			for j := i + 1; j < len(parts); j++ {
-				remainingPart := parts[j]
-				paramType := c.classifyParameterType(remainingPart)
-				normalized = append(normalized, "{"+paramType+"}")
+				seg, ok := c.overridden(parts, j)
+				if !ok {
+					seg = c.variableSegment(node, parts[j], RuleVariableTail)
+				}
+				normalized = append(normalized, seg)
 			}
-			break
+			return normalized, nil, false
+		}
+
+		return append(normalized, c.unlearned(parts, i)...), nil, false
+	}
+
+	return normalized, node, false
+}
+
+// unlearned returns the segments for parts[i:], which left the learned
+// trie, so pass through as-is unless SegmentOverride decides them.
+func (c *Classifier) unlearned(parts []string, i int) []normalizedSegment {
+	normalized := make([]normalizedSegment, 0, len(parts)-i)
+	for j := i; j < len(parts); j++ {
+		seg, ok := c.overridden(parts, j)
+		if !ok {
+			seg = c.literalFor(nil, parts[j], RuleUnlearned)
+		}
+		normalized = append(normalized, seg)
+	}
+	return normalized
+}
+
+// overridden returns the segment SegmentOverride forces for parts[i], if
+// it forces one.
+func (c *Classifier) overridden(parts []string, i int) (normalizedSegment, bool) {
+	fn := c.config.SegmentOverride
+	if fn == nil {
+		return normalizedSegment{}, false
+	}
+	label, force := fn(parts[:i:i], parts[i])
+	if !force {
+		return normalizedSegment{}, false
+	}
+	seg := literalSegment(parts[i])
+	if label != "" {
+		seg = paramSegment(label)
+	}
+	seg.confidence = 1.0 // decided by the caller
+	seg.raw, seg.rule = parts[i], RuleOverride
+	return seg, true
+}
+
+// overrideStep returns the node to continue from after an overridden part
+// below node, as the built-in logic would have continued: through the
+// wildcard of a collapsed node, the merged children at a variable position,
+// or else the part's child. It returns nil if the part leaves the trie.
+func (c *Classifier) overrideStep(node *Segment, part string) *Segment {
+	if node.collapsed {
+		return node.children["*"]
+	}
+	if c.hasHighVariability(node) {
+		if virtualNode := c.commonChildrenNode(node); virtualNode != nil {
+			return virtualNode
+		}
+	}
+	return node.children[part]
+}
+
+// decisionConfidence scores how strongly node's children support treating
+// the segment below it as a parameter: the observed variability (1.0 for
+// collapsed nodes and pattern-matched single children) weighted by sample
+// count. The weight is 0.5 at exactly MinSamples and approaches 1.0 as
+// samples grow.
+func (c *Classifier) decisionConfidence(node *Segment) float64 {
+	total := 0
+	for _, child := range node.children {
+		total += child.totalCount.load()
+	}
+	if total == 0 {
+		return 0
+	}
+
+	variability := float64(len(node.children)) / float64(total)
+	if node.collapsed || len(node.children) == 1 {
+		variability = 1.0
+	}
+
+	samples := float64(total)
+	weight := samples / (samples + float64(max(c.config.MinSamples, 1)))
+	return min(variability, 1.0) * weight
+}
+
+// variableDepthTail reports whether the children of node, a variable
+// position, lead to paths of different lengths with no shared continuation
+// seen at least MinSamples times, as with /files/a/b/c.txt and /files/d.txt.
+// Fixed-depth routes like /users/{id} and /users/{id}/profile share their
+// continuation and are not affected.
+func (c *Classifier) variableDepthTail(node *Segment) bool {
+	var depths endSpan
+	continuations := make(map[string]int)
+	for _, child := range node.children {
+		depths.add(c.endDepths(child), 0)
+		for key, grandchild := range child.children {
+			continuations[key] += grandchild.totalCount.load()
 		}
+	}
+	if !depths.any || depths.min == depths.max {
+		return false
+	}
 
-		for j := i; j < len(parts); j++ {
-			normalized = append(normalized, parts[j])
+	for _, count := range continuations {
+		if count >= max(c.config.MinSamples, 2) {
+			return false
 		}
-		break
 	}
+	return true
+}
+
+// endSpan is the range of depths, relative to a node, at which learned URLs
+// end within its subtree.
+type endSpan struct {
+	shape    uint64 // Classifier.shape the span was computed at
+	any      bool   // some learned URL ends in the subtree
+	min, max int
+}
+
+// add widens span by other, whose depths lie offset levels deeper.
+func (span *endSpan) add(other endSpan, offset int) {
+	if !other.any {
+		return
+	}
+	if !span.any {
+		span.any, span.min, span.max = true, other.min+offset, other.max+offset
+		return
+	}
+	span.min = min(span.min, other.min+offset)
+	span.max = max(span.max, other.max+offset)
+}
 
-	return "/" + strings.Join(normalized, "/"), nil
+// endDepths returns the end depths within node's subtree. It is cached on
+// node until the trie's shape changes, so classifying through a wildcard
+// tail doesn't walk the subtree every time. Caller must hold at least the
+// read lock; learnFast, which runs under it, never adds an end.
+func (c *Classifier) endDepths(node *Segment) endSpan {
+	if span := node.ends.Load(); span != nil && span.shape == c.shape {
+		return *span
+	}
+	span := endSpan{shape: c.shape}
+	if node.isEnd {
+		span.any = true
+	}
+	for _, child := range node.children {
+		span.add(c.endDepths(child), 1)
+	}
+	node.ends.Store(&span)
+	return span
 }
 
 func (c *Classifier) shouldParameterize(segment *Segment) bool {
-	if segment.totalCount < c.config.MinSamples {
+	if segment.totalCount.load() < c.config.MinSamples {
 		return false
 	}
 
@@ -310,33 +1845,126 @@ func (c *Classifier) shouldParameterize(segment *Segment) bool {
 }
 
 func (c *Classifier) hasHighVariability(node *Segment) bool {
+	return c.variabilityRule(node) != RuleStatic
+}
+
+// variabilityRule reports which rule, if any, makes the children of node
+// variable. RuleStatic means they are not.
+func (c *Classifier) variabilityRule(node *Segment) DecisionRule {
+	// Locales are a known set of values, not identifiers
+	if !c.config.LocaleAsParam && localeChildren(node) {
+		return RuleStatic
+	}
+
+	if c.enumChildren(node) {
+		return RuleStatic
+	}
+
+	if len(node.children) < c.config.MinDistinctValues {
+		return RuleStatic
+	}
+
 	// Special case: if there's only one child but it's been traversed multiple times
 	// and looks like a parameter pattern, treat it as variable
 	if len(node.children) == 1 {
 		for childValue, child := range node.children {
-			if child.totalCount >= c.config.MinSamples && c.looksLikeParameter(childValue) {
-				return true
+			if child.totalCount.load() >= c.config.MinSamples && c.looksLikeParameter(childValue) {
+				return RuleSingleChildParameter
 			}
 		}
 	}
 
-	minChildren := 3
-	if c.config.CardinalityThreshold < 0.75 {
-		minChildren = 2
-	}
-
-	if len(node.children) < minChildren {
-		return false
+	if len(node.children) < c.config.minChildrenForVariability() {
+		return RuleStatic
 	}
 
 	totalTraversals := 0
 	for _, child := range node.children {
-		totalTraversals += child.totalCount
+		totalTraversals += child.totalCount.load()
 	}
 
 	variability := float64(len(node.children)) / float64(totalTraversals)
 
-	return variability >= c.config.CardinalityThreshold
+	if variability >= c.config.CardinalityThreshold {
+		return RuleHighVariability
+	}
+	return RuleStatic
+}
+
+// enumChildren reports whether node's children look like a small fixed set
+// of values (see WithMaxEnumValues) rather than identifiers.
+func (c *Classifier) enumChildren(node *Segment) bool {
+	n := c.config.MaxEnumValues
+	if n <= 0 || len(node.children) == 0 || len(node.children) > n || node.collapsed {
+		return false
+	}
+	for value, child := range node.children {
+		if child.totalCount.load() < max(c.config.MinSamples, 2) || c.looksLikeParameter(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// commonChildrenNode returns a virtual node holding the merged children of
+// all of node's children, or nil if they have none. The result is cached on
+// node and kept in sync by insert, so repeated classifications through a
+// high-variability node don't rebuild it.
+func (c *Classifier) commonChildrenNode(node *Segment) *Segment {
+	virtualNode := c.mergedChildrenNode(node)
+	if len(virtualNode.children) == 0 {
+		return nil
+	}
+	return virtualNode
+}
+
+// mergedChildrenNode is commonChildrenNode without the nil result for a
+// childless merge; the virtual node still stands for the parameter position.
+//
+// The view is built under the read lock, so learnFast may bump counters
+// while it is copied. It is only cached if no learnFast below the owning
+// trie node was in flight when the build began, and withdrawn if one began
+// before it was published: learnFast calls that began later see the cached
+// view and update it in place, so it never misses an increment for good.
+func (c *Classifier) mergedChildrenNode(node *Segment) *Segment {
+	if virtualNode := node.merged.Load(); virtualNode != nil {
+		return virtualNode
+	}
+
+	state := node.viewOwner().fastLearns.Load()
+	virtualNode := c.buildMerged(node)
+	cacheMerged(node, virtualNode, state)
+	return virtualNode
+}
+
+// buildMerged builds the merged view of node's children.
+func (c *Classifier) buildMerged(node *Segment) *Segment {
+	owner := node.viewOwner()
+	virtualNode := &Segment{
+		value:    "",
+		children: c.findCommonChildrenAcrossAllSiblings(node),
+		isEnd:    false,
+		owner:    owner,
+	}
+	for _, child := range node.children {
+		virtualNode.slashEnd = virtualNode.slashEnd || child.slashEnd
+	}
+	if virtualNode.children == nil {
+		virtualNode.children = make(map[string]*Segment)
+	}
+	for _, mergedChild := range virtualNode.children {
+		mergedChild.owner = owner
+	}
+	return virtualNode
+}
+
+// cacheMerged caches virtualNode as node's merged view if no learnFast
+// touched the owner since its fastLearns read state, taken before the build.
+func cacheMerged(node, virtualNode *Segment, state uint64) {
+	owner := virtualNode.owner
+	if uint32(state) == 0 && node.merged.CompareAndSwap(nil, virtualNode) && owner.fastLearns.Load() != state {
+		node.merged.CompareAndSwap(virtualNode, nil)
+	}
 }
 
 func (c *Classifier) findCommonChildrenAcrossAllSiblings(node *Segment) map[string]*Segment {
@@ -344,14 +1972,25 @@ func (c *Classifier) findCommonChildrenAcrossAllSiblings(node *Segment) map[stri
 		return nil
 	}
 
+	// Sorted so the merge doesn't depend on map iteration order
+	keys := make([]string, 0, len(node.children))
+	for key := range node.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	allChildren := make([]*Segment, 0, len(node.children))
-	for _, child := range node.children {
-		allChildren = append(allChildren, child)
+	for _, key := range keys {
+		allChildren = append(allChildren, node.children[key])
 	}
 
 	return c.mergeChildren(allChildren)
 }
 
+// mergeChildren merges the children of segments by name, summing their
+// stats. Where several segments share a grandchild name, the grandchild of
+// the first segment in order is referenced, so callers must pass segments
+// in a deterministic order.
 func (c *Classifier) mergeChildren(segments []*Segment) map[string]*Segment {
 	if len(segments) == 0 {
 		return nil
@@ -377,9 +2016,10 @@ func (c *Classifier) mergeChildren(segments []*Segment) map[string]*Segment {
 			}
 
 			for value, count := range childNode.values {
-				mergedChild.values[value] += count
+				mergedChild.addValue(value, count.load())
 			}
-			mergedChild.totalCount += childNode.totalCount
+			mergedChild.totalCount.add(childNode.totalCount.load())
+			mergedChild.slashEnd = mergedChild.slashEnd || childNode.slashEnd
 		}
 
 		result[childName] = mergedChild
@@ -388,45 +2028,124 @@ func (c *Classifier) mergeChildren(segments []*Segment) map[string]*Segment {
 	return result
 }
 
+// Parameter detection patterns, compiled once since they run for every
+// segment of every classification.
+var (
+	uuidPattern         = regexp.MustCompile(`^(?:[0-9a-f]{32}|(?:urn:uuid:)?[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|\{[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\})$`) // dashed, dashless, urn:uuid: or braced
+	datePattern         = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	datetimePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`) // RFC 3339
+	timestampPattern    = regexp.MustCompile(`^\d{10,}$`)
+	paddedIDPattern     = regexp.MustCompile(`^0\d{4,}$`) // fixed-width, zero-padded: 00012345
+	objectIDPattern     = regexp.MustCompile(`^[0-9a-f]{24}$`)
+	hashPattern         = regexp.MustCompile(`^[0-9a-f]{25,}$`)
+	ulidPattern         = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`) // Crockford base32, no I/L/O/U
+	prefixedIDPattern   = prefixedIDRegexp(nil)
+	objectPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	slugWithIDPattern   = regexp.MustCompile(`^[A-Za-z0-9]+-[A-Za-z0-9-]+-\d+$`)
+	slugPattern         = regexp.MustCompile(`^([a-z0-9]+(-[a-z0-9]+)*(-\d+)?|[A-Za-z0-9]+(-[A-Za-z0-9]+)+)$`) // uppercase only with hyphens: My-Post-123
+	firestoreIDPattern  = regexp.MustCompile(`^[A-Za-z0-9]{20}$`)
+	tokenPattern        = regexp.MustCompile(`^[A-Za-z0-9+_-]+={0,2}$`) // base64 or base64url; "/" can't appear in a segment
+	colorPattern        = regexp.MustCompile(`^(?:[0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+	phonePattern        = regexp.MustCompile(`^\+[1-9]\d{6,14}$`)                             // E.164: country code first, 7-15 digits
+	base64URLPattern    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)                              // unpadded base64url, as in JWTs
+	filenamePattern     = regexp.MustCompile(`^[\w-]+(\.[\w-]+)*\.[A-Za-z][A-Za-z0-9]{0,4}$`) // extension starts with a letter, so v1.2 isn't a file
+	decimalPattern      = regexp.MustCompile(`^[+-]?\d+\.\d+$`)
+	versionPattern      = regexp.MustCompile(`^v\d+$`)
+	semverPattern       = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+	emailPattern        = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}$`)
+	embeddedDatePattern = regexp.MustCompile(`^(.*\D)?(\d{4}-\d{2}-\d{2}|\d{8})(\D.*)?$`)
+)
+
+// stripePrefixes are the object prefixes of Stripe IDs like cus_abc123.
+var stripePrefixes = []string{"cus", "sub", "prod", "price", "pm", "pi", "ch", "in", "tok", "src", "ba", "card"}
+
+// prefixedIDRegexp matches prefix_alnum IDs with a Stripe prefix or one of
+// extra, which must be alphanumeric.
+func prefixedIDRegexp(extra []string) *regexp.Regexp {
+	prefixes := append(append([]string(nil), stripePrefixes...), extra...)
+	return regexp.MustCompile(`^(` + strings.Join(prefixes, "|") + `)_[a-zA-Z0-9]+$`)
+}
+
 func (c *Classifier) looksLikeParameter(value string) bool {
-	if matched, _ := regexp.MatchString(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`, value); matched {
+	if _, ok := c.matchCustomType(value); ok {
+		return true
+	}
+
+	for _, detector := range c.userDetectors {
+		if _, ok := detector.Detect(value); ok {
+			return true
+		}
+	}
+
+	if _, pinned := c.pinnedLiteral(value); pinned {
+		return false
+	}
+
+	if uuidPattern.MatchString(value) {
+		return true
+	}
+
+	if datePattern.MatchString(value) || datetimePattern.MatchString(value) {
+		return true
+	}
+
+	if c.longNumberType(value) != "" {
+		return true
+	}
+
+	if objectIDPattern.MatchString(value) {
+		return true
+	}
+
+	if hashPattern.MatchString(value) {
+		return true
+	}
+
+	if ulidPattern.MatchString(value) {
+		return true
+	}
+
+	if isJWT(value) {
+		return true
+	}
+
+	if phonePattern.MatchString(value) {
+		return true
+	}
+
+	if ipVersion(value) != "" {
 		return true
 	}
 
-	if matched, _ := regexp.MatchString(`^\d{4}-\d{2}-\d{2}$`, value); matched {
+	if emailPattern.MatchString(value) {
 		return true
 	}
 
-	if matched, _ := regexp.MatchString(`^\d{10,}$`, value); matched {
+	if semverPattern.MatchString(value) {
 		return true
 	}
 
-	if matched, _ := regexp.MatchString(`^[0-9a-f]{24,}$`, value); matched {
+	if c.prefixedID.MatchString(value) {
 		return true
 	}
 
-	if matched, _ := regexp.MatchString(`^(cus|sub|prod|price|pm|pi|ch|in|tok|src|ba|card)_[a-zA-Z0-9]+$`, value); matched {
+	if c.looksLikeToken(value) {
 		return true
 	}
 
 	if num, err := strconv.ParseInt(value, 10, 64); err == nil {
-		if num >= 100 && num < 2000 {
-			return true
-		}
-		if num >= 2100 && num < 10000 {
-			return true
-		}
-		if num >= 100000 {
-			return true
-		}
-		return false
+		return c.numericID(num)
+	}
+
+	if c.looksLikeColor(value) && strings.ContainsAny(value, "0123456789") {
+		return true
 	}
 
 	// Slug pattern with specific characteristics that suggest it's a dynamic value
 	// Must contain at least one hyphen AND either:
 	// - ends with digits
 	// - has multiple segments
-	if matched, _ := regexp.MatchString(`^[a-z0-9]+-[a-z0-9-]+-\d+$`, value); matched {
+	if slugWithIDPattern.MatchString(value) {
 		return true // Slug ending with numeric ID (e.g., "my-post-12345")
 	}
 
@@ -434,48 +2153,285 @@ func (c *Classifier) looksLikeParameter(value string) bool {
 }
 
 func (c *Classifier) classifyParameterType(value string) string {
-	if matched, _ := regexp.MatchString(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`, value); matched {
-		return "uuid"
+	if name, ok := c.matchCustomType(value); ok {
+		return name
 	}
 
-	if matched, _ := regexp.MatchString(`^\d{4}-\d{2}-\d{2}$`, value); matched {
-		return "date"
+	for _, detector := range c.detectors {
+		if name, ok := detector.Detect(value); ok {
+			return name
+		}
 	}
 
-	if matched, _ := regexp.MatchString(`^\d{10,}$`, value); matched {
-		return "timestamp"
+	if name := c.config.DefaultParamName; name != "" {
+		return name
 	}
+	return "param"
+}
 
-	if matched, _ := regexp.MatchString(`^[0-9a-f]{24,}$`, value); matched {
-		return "hash"
+// looksLikeFirestoreID matches 20-char Firestore auto-IDs. Requiring both
+// letter cases keeps ordinary 20-letter words and digit runs out. It is only
+// consulted once siblings already show high variability.
+func (c *Classifier) looksLikeFirestoreID(value string) bool {
+	if !firestoreIDPattern.MatchString(value) {
+		return false
 	}
+	return strings.ContainsAny(value, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+		strings.ContainsAny(value, "abcdefghijklmnopqrstuvwxyz")
+}
 
-	if matched, _ := regexp.MatchString(`^(cus|sub|prod|price|pm|pi|ch|in|tok|src|ba|card)_[a-zA-Z0-9]+$`, value); matched {
+// longNumberType returns the parameter type of all-digit values that aren't
+// judged by magnitude: "id" for zero-padded fixed-width IDs, whose value
+// (00012345 is 12345) says nothing, and for integers of at least
+// TimestampDigitThreshold digits, "timestamp" for other integers of ten or
+// more digits, and "" for the rest.
+func (c *Classifier) longNumberType(value string) string {
+	if paddedIDPattern.MatchString(value) {
+		return "id"
+	}
+	if !timestampPattern.MatchString(value) {
+		return ""
+	}
+	if n := c.config.TimestampDigitThreshold; n > 0 && len(value) >= n {
 		return "id"
 	}
+	return "timestamp"
+}
 
-	if num, err := strconv.ParseInt(value, 10, 64); err == nil {
-		if num >= 100 && num < 10000 {
-			return "id"
-		}
-		if num >= 100000 {
-			return "id"
-		}
+// numericID reports whether num looks like an ID rather than a page number,
+// count or year. Small numbers (< 100) and 10000–99999 are excluded, and so
+// are years (2000–2099) unless YearAsID is set. ParseInt accepts a leading
+// sign; the ranges apply to the magnitude.
+func (c *Classifier) numericID(num int64) bool {
+	num = absInt(num)
+	if num >= 2000 && num < 2100 {
+		return c.config.YearAsID
+	}
+	return (num >= 100 && num < 10000) || num >= 100000
+}
+
+// absInt returns the magnitude of n, saturating at math.MaxInt64.
+func absInt(n int64) int64 {
+	if n >= 0 {
+		return n
+	}
+	if n == math.MinInt64 {
+		return math.MaxInt64
 	}
+	return -n
+}
 
-	if matched, _ := regexp.MatchString(`^[a-z0-9]+(-[a-z0-9]+)*(-\d+)?$`, value); matched {
-		return "slug"
+// ipVersion returns "ipv4" or "ipv6" if value is an IP address literal, or
+// "" otherwise. IPv6 addresses contain colons, which are not path separators,
+// so they arrive here as a single segment.
+func ipVersion(value string) string {
+	if net.ParseIP(value) == nil {
+		return ""
+	}
+	if strings.Contains(value, ":") {
+		return "ipv6"
 	}
+	return "ipv4"
+}
 
-	return "param"
+// looksLikeToken reports whether value is an opaque base64 or base64url
+// string of at least MinTokenLength characters. Words and slugs share the
+// alphabet, so it also needs a base64 marker (padding or "+") or the mix of
+// upper case, lower case and digits that encoded bytes produce.
+func (c *Classifier) looksLikeToken(value string) bool {
+	if len(value) < c.config.MinTokenLength || !tokenPattern.MatchString(value) {
+		return false
+	}
+	if strings.HasSuffix(value, "=") {
+		return len(value)%4 == 0
+	}
+	if strings.Contains(value, "+") {
+		return true
+	}
+	return strings.ContainsAny(value, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+		strings.ContainsAny(value, "abcdefghijklmnopqrstuvwxyz") &&
+		strings.ContainsAny(value, "0123456789")
+}
+
+// isJWT reports whether value is a JSON Web Token: three base64url parts
+// separated by dots, header and payload being JSON objects (so both start
+// with "eyJ"). The header must also decode and name its "alg", which keeps
+// dotted filenames out. The signature is empty for unsecured tokens.
+func isJWT(value string) bool {
+	header, rest, _ := strings.Cut(value, ".")
+	payload, signature, ok := strings.Cut(rest, ".")
+	if !ok || !strings.HasPrefix(header, "eyJ") || !strings.HasPrefix(payload, "eyJ") ||
+		!base64URLPattern.MatchString(payload) ||
+		(signature != "" && !base64URLPattern.MatchString(signature)) {
+		return false
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(decoded, &fields); err != nil {
+		return false
+	}
+	_, ok = fields["alg"]
+	return ok
+}
+
+// looksLikeColor reports whether value is a hex color code and color
+// detection is on.
+func (c *Classifier) looksLikeColor(value string) bool {
+	return c.config.ColorDetection && colorPattern.MatchString(value)
+}
+
+// embeddedParam extracts a date embedded in static text within a single
+// segment. The whole segment being a date is left to classifyParameterType.
+func (c *Classifier) embeddedParam(part string) (normalizedSegment, bool) {
+	if !c.config.EmbeddedDates {
+		return normalizedSegment{}, false
+	}
+
+	// A whole datetime is its own type, not a date with a time suffix
+	if datetimePattern.MatchString(part) {
+		return paramSegment("datetime"), true
+	}
+
+	m := embeddedDatePattern.FindStringSubmatch(part)
+	if m == nil || (m[1] == "" && m[3] == "") || !validDate(m[2]) {
+		return normalizedSegment{}, false
+	}
+
+	seg := paramSegment("date")
+	seg.prefix = m[1]
+	seg.suffix = m[3]
+	return seg, true
+}
+
+// validDate reports whether a YYYY-MM-DD or YYYYMMDD string is a plausible
+// calendar date, which keeps arbitrary 8-digit numbers out.
+func validDate(value string) bool {
+	value = strings.ReplaceAll(value, "-", "")
+	year, _ := strconv.Atoi(value[0:4])
+	month, _ := strconv.Atoi(value[4:6])
+	day, _ := strconv.Atoi(value[6:8])
+	return year >= 1900 && year < 2100 && month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
+// splitHost separates a full URL (scheme://[userinfo@]host[:port]/path) into
+// its host and the remaining path, query and fragment. Anything that doesn't
+// parse as an absolute URL with a host is returned unchanged as the path.
+func splitHost(rawURL string) (host, path string) {
+	schemeEnd := strings.Index(rawURL, "://")
+	if schemeEnd <= 0 || strings.Contains(rawURL[:schemeEnd], "/") {
+		return "", rawURL
+	}
+
+	u, err := neturl.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", rawURL
+	}
+
+	rest := rawURL[schemeEnd+len("://"):]
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		path = rest[i:]
+	}
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	return u.Host, path
+}
+
+// escapeSeparators percent-encodes the slashes and separators in a decoded
+// segment, so they don't read as path boundaries once rendered.
+func escapeSeparators(segment, sep string) string {
+	for _, s := range []string{"/", sep} {
+		if !strings.Contains(segment, s) {
+			continue
+		}
+		var escaped strings.Builder
+		for i := 0; i < len(s); i++ {
+			fmt.Fprintf(&escaped, "%%%02X", s[i])
+		}
+		segment = strings.ReplaceAll(segment, s, escaped.String())
+	}
+	return segment
 }
 
 func (c *Classifier) splitURL(url string) []string {
-	url = strings.TrimPrefix(url, "/")
+	parts, _ := c.splitPath(url)
+	return parts
+}
+
+// splitPath splits url into path segments and reports whether it ended with
+// a trailing slash (or an index file). Unless TrailingSlashPreserve is set,
+// the trailing slash is dropped from the segments.
+func (c *Classifier) splitPath(url string) (parts []string, slash bool) {
+	_, url = splitHost(url)
+	if c.config.ClassifyQuery {
+		url, _ = splitQuery(url)
+	}
+	sep := c.config.separator()
+	url = strings.TrimPrefix(url, sep)
 
 	if url == "" {
-		return []string{}
+		return []string{}, false
+	}
+
+	parts = strings.Split(url, sep)
+
+	// Strip before decoding so an encoded %3B isn't taken for a delimiter
+	if c.config.StripMatrixParams {
+		for i, part := range parts {
+			parts[i], _, _ = strings.Cut(part, ";")
+		}
+	}
+
+	// Decode after splitting so an encoded %2F stays inside its segment
+	if c.config.DecodeSegments {
+		for i, part := range parts {
+			if decoded, err := neturl.PathUnescape(part); err == nil {
+				parts[i] = escapeSeparators(decoded, sep)
+			}
+		}
+	}
+
+	// Keep a leading NUL encoded, reserving it for mergedWildcardKey
+	for i, part := range parts {
+		if strings.HasPrefix(part, "\x00") {
+			parts[i] = "%00" + part[1:]
+		}
+	}
+
+	// Drop empty segments from doubled slashes, keeping a trailing one
+	if c.config.CollapseEmptySegments {
+		kept := parts[:0]
+		for i, part := range parts {
+			if part != "" || i == len(parts)-1 {
+				kept = append(kept, part)
+			}
+		}
+		parts = kept
 	}
 
-	return strings.Split(url, "/")
+	// Fold a trailing index file into its directory form (trailing slash)
+	last := parts[len(parts)-1]
+	for _, name := range c.config.IndexFiles {
+		if last == name {
+			parts[len(parts)-1] = ""
+			break
+		}
+	}
+
+	if parts[len(parts)-1] == "" {
+		slash = true
+		if c.config.TrailingSlash != TrailingSlashPreserve {
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	// Fold everything past MaxDepth into one tail segment
+	if n := c.config.MaxDepth; n > 0 && len(parts) > n+1 {
+		parts = append(parts[:n], strings.Join(parts[n:], sep))
+	}
+	return parts, slash
 }
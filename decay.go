@@ -0,0 +1,103 @@
+package classifier
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Decay multiplies every learned count by factor, rounding down, so old
+// observations weigh less than new ones. Values, routes and nodes whose
+// count drops below one are removed. Calling it on a schedule lets the
+// classifier adapt when traffic changes, e.g. when an endpoint switches from
+// static names to IDs. factor must be in [0, 1]; 0 forgets everything.
+// LearnedCount is not affected.
+func (c *Classifier) Decay(factor float64) error {
+	if !(factor >= 0 && factor <= 1) {
+		return fmt.Errorf("decay factor must be in [0, 1], got %v", factor)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decay(factor)
+	return nil
+}
+
+// decay implements Decay. Caller must hold the write lock.
+func (c *Classifier) decay(factor float64) {
+	for _, root := range c.roots() {
+		decayCounts(root, factor)
+		decayChildren(root, factor)
+		c.clearMerged(root)
+	}
+	c.shape++
+	for key, seg := range c.queryKeys {
+		decayCounts(seg, factor)
+		if seg.totalCount.load() == 0 {
+			delete(c.queryKeys, key)
+		}
+	}
+	c.nodes = c.countAllNodes()
+}
+
+// maybeDecay applies the half-life decay due since the last one, once at
+// least a full HalfLife has passed. Caller must hold the write lock.
+func (c *Classifier) maybeDecay() {
+	if c.config.HalfLife <= 0 {
+		return
+	}
+	now := c.config.Clock()
+	if c.lastDecay.IsZero() {
+		c.lastDecay = now
+		return
+	}
+	elapsed := now.Sub(c.lastDecay)
+	if elapsed < c.config.HalfLife {
+		return
+	}
+	c.decay(math.Pow(0.5, float64(elapsed)/float64(c.config.HalfLife)))
+	c.lastDecay = now
+}
+
+// decayChildren decays the subtree below node, removing children that are
+// no longer traversed or lead nowhere.
+func decayChildren(node *Segment, factor float64) {
+	for key, child := range node.children {
+		decayCounts(child, factor)
+		if child.totalCount.load() == 0 {
+			delete(node.children, key)
+			continue
+		}
+		decayChildren(child, factor)
+		if len(child.children) == 0 && !child.isEnd {
+			delete(node.children, key) // nothing left below it
+		}
+	}
+	if node.collapsed && len(node.children) == 0 {
+		node.collapsed = false
+	}
+}
+
+// decayCounts scales the counts held by s itself.
+func decayCounts(s *Segment, factor float64) {
+	s.totalCount.store(scaleCount(s.totalCount.load(), factor))
+	for value, cnt := range s.values {
+		if n := scaleCount(cnt.load(), factor); n > 0 {
+			cnt.store(n)
+		} else {
+			delete(s.values, value)
+		}
+	}
+	if s.isEnd {
+		s.endCount.store(scaleCount(s.endCount.load(), factor))
+		if s.endCount.load() == 0 {
+			s.isEnd, s.slashEnd = false, false
+			s.firstSeen = time.Time{}
+			s.lastSeen.Store(0)
+		}
+	}
+}
+
+func scaleCount(n int, factor float64) int {
+	return int(float64(n) * factor)
+}
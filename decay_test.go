@@ -0,0 +1,90 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDecay(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	for i := 0; i < 50; i++ {
+		c.Learn([]string{"/reports/daily", "/reports/weekly"})
+	}
+
+	// The endpoint switches to IDs, but the old names pin it static
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, fmt.Sprintf("/reports/%d", 100001+i))
+	}
+	c.Learn(ids)
+	if result, _ := c.Classify("/reports/100001"); result != "/reports/100001" {
+		t.Fatalf("Classify() before decay = %v, want /reports/100001", result)
+	}
+
+	if err := c.Decay(0.01); err != nil {
+		t.Fatalf("Decay() unexpected error: %v", err)
+	}
+	if patterns := c.Patterns(); len(patterns) != 0 {
+		t.Errorf("Patterns() after decay = %v, want none", patterns)
+	}
+	if got := c.NodeCount(); got != 1 {
+		t.Errorf("NodeCount() after decay = %d, want 1", got)
+	}
+
+	c.Learn(ids)
+	if result, _ := c.Classify("/reports/100001"); result != "/reports/{id}" {
+		t.Errorf("Classify() after relearning = %v, want /reports/{id}", result)
+	}
+}
+
+func TestDecayScalesCounts(t *testing.T) {
+	c := NewClassifier()
+	for i := 0; i < 4; i++ {
+		c.Learn([]string{"/api/v1/health"})
+	}
+	c.Learn([]string{"/api/v1/status"})
+
+	if err := c.Decay(0.5); err != nil {
+		t.Fatalf("Decay() unexpected error: %v", err)
+	}
+
+	counts := c.PatternCounts()
+	if counts["/api/v1/health"] != 2 {
+		t.Errorf("count of /api/v1/health = %d, want 2", counts["/api/v1/health"])
+	}
+	if _, ok := counts["/api/v1/status"]; ok {
+		t.Errorf("/api/v1/status survived decay: %v", counts)
+	}
+	if got := c.LearnedCount(); got != 5 {
+		t.Errorf("LearnedCount() = %d, want 5", got)
+	}
+}
+
+func TestDecayInvalidFactor(t *testing.T) {
+	c := NewClassifier()
+	for _, factor := range []float64{-0.5, 1.5} {
+		if err := c.Decay(factor); err == nil {
+			t.Errorf("Decay(%v) expected error", factor)
+		}
+	}
+}
+
+func TestWithHalfLife(t *testing.T) {
+	now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	c := NewClassifier(WithClock(clock), WithHalfLife(time.Hour))
+
+	for i := 0; i < 8; i++ {
+		c.Learn([]string{"/api/v1/health"})
+	}
+
+	// Two half-lives later the next insert first quarters the old counts
+	now = now.Add(2 * time.Hour)
+	c.Learn([]string{"/api/v1/status"})
+
+	counts := c.PatternCounts()
+	if counts["/api/v1/health"] != 2 || counts["/api/v1/status"] != 1 {
+		t.Errorf("PatternCounts() = %v, want health 2 and status 1", counts)
+	}
+}
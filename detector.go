@@ -0,0 +1,106 @@
+package classifier
+
+import "strconv"
+
+// ParameterDetector recognizes a kind of dynamic path segment and names its
+// parameter type, e.g. "uuid" for d381b052-99eb-40f2-9ede-9bce790faae1.
+type ParameterDetector interface {
+	Detect(segment string) (typeName string, ok bool)
+}
+
+// DetectorFunc adapts an ordinary function to a ParameterDetector.
+type DetectorFunc func(segment string) (typeName string, ok bool)
+
+func (f DetectorFunc) Detect(segment string) (string, bool) {
+	return f(segment)
+}
+
+func (ct customParameterType) Detect(segment string) (string, bool) {
+	if ct.pattern.MatchString(segment) {
+		return ct.name, true
+	}
+	return "", false
+}
+
+// defaultDetectors returns the built-in detectors for specific value shapes
+// in precedence order. Detectors that depend on configuration read it at
+// call time.
+func (c *Classifier) defaultDetectors() []ParameterDetector {
+	return []ParameterDetector{
+		customParameterType{"uuid", uuidPattern},
+		customParameterType{"date", datePattern},
+		customParameterType{"datetime", datetimePattern},
+		DetectorFunc(func(segment string) (string, bool) {
+			paramType := c.longNumberType(segment)
+			return paramType, paramType != ""
+		}),
+		customParameterType{"objectid", objectIDPattern},
+		customParameterType{"hash", hashPattern},
+		customParameterType{"ulid", ulidPattern},
+		DetectorFunc(func(segment string) (string, bool) {
+			return "jwt", isJWT(segment)
+		}),
+		customParameterType{"phone", phonePattern},
+		DetectorFunc(func(segment string) (string, bool) {
+			version := ipVersion(segment)
+			return version, version != ""
+		}),
+		customParameterType{"email", emailPattern},
+		customParameterType{"semver", semverPattern},
+		DetectorFunc(func(segment string) (string, bool) {
+			return "locale", c.config.LocaleAsParam && isLocale(segment)
+		}),
+		DetectorFunc(func(segment string) (string, bool) {
+			return "firestoreid", c.looksLikeFirestoreID(segment)
+		}),
+		customParameterType{"id", c.prefixedID}, // Stripe-style prefixed IDs
+		DetectorFunc(func(segment string) (string, bool) {
+			return "token", c.looksLikeToken(segment)
+		}),
+		DetectorFunc(func(segment string) (string, bool) {
+			num, err := strconv.ParseInt(segment, 10, 64)
+			return "id", err == nil && c.numericID(num)
+		}),
+		DetectorFunc(func(segment string) (string, bool) {
+			return "color", c.looksLikeColor(segment)
+		}),
+		customParameterType{"float", decimalPattern},
+	}
+}
+
+// fallbackDetectors returns the built-in catch-alls for generic shapes,
+// consulted after AdditionalDetectors so that those get a chance first.
+func fallbackDetectors() []ParameterDetector {
+	return []ParameterDetector{
+		customParameterType{"filename", filenamePattern},
+		customParameterType{"slug", slugPattern},
+	}
+}
+
+// buildDetectors assembles the detector chain from the config: Detectors
+// followed by AdditionalDetectors, or if Detectors is unset the specific
+// built-ins, AdditionalDetectors and the built-in fallbacks. Types added
+// with RegisterParameterType are matched before the whole chain. It also
+// builds the prefixed ID pattern the built-ins use.
+func (c *Classifier) buildDetectors() {
+	c.prefixedID = prefixedIDPattern
+	if len(c.config.ObjectPrefixes) > 0 {
+		c.prefixedID = prefixedIDRegexp(c.config.ObjectPrefixes)
+	}
+	base := c.config.Detectors
+	c.userDetectors = append(append([]ParameterDetector(nil), base...), c.config.AdditionalDetectors...)
+	if base != nil {
+		c.detectors = c.userDetectors
+		return
+	}
+	c.detectors = append(append(c.defaultDetectors(), c.config.AdditionalDetectors...), fallbackDetectors()...)
+}
+
+// withoutDetectors returns a copy of cfg fit for serialization; detectors
+// are code and can't be encoded.
+func (cfg *Config) withoutDetectors() *Config {
+	cp := *cfg
+	cp.Detectors = nil
+	cp.AdditionalDetectors = nil
+	return &cp
+}
@@ -0,0 +1,190 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/gob"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDefaultDetectors(t *testing.T) {
+	// Locks in the built-in detector chain; changing an expectation here
+	// changes classification output for existing users.
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"d381b052-99eb-40f2-9ede-9bce790faae1", "uuid"},
+		{"d381b05299eb40f29ede9bce790faae1", "uuid"},
+		{"urn:uuid:d381b052-99eb-40f2-9ede-9bce790faae1", "uuid"},
+		{"{d381b052-99eb-40f2-9ede-9bce790faae1}", "uuid"},
+		{"2024-01-15", "date"},
+		{"2024-01-15T10:30:00Z", "datetime"},
+		{"1705334400", "timestamp"},
+		{"1541815603606036480", "timestamp"},
+		{"00012345", "id"},
+		{"0000012345", "id"},
+		{"507f1f77bcf86cd799439011", "objectid"},
+		{"da39a3ee5e6b4b0d3255bfef95601890afd80709", "hash"},
+		{"01ARZ3NDEKTSV4RRFFQ69G5FAV", "ulid"},
+		{"eyJhbGciOiJub25lIn0.eyJzdWIiOiIxMjMifQ.", "jwt"},
+		{"+14155552671", "phone"},
+		{"192.168.1.10", "ipv4"},
+		{"2001:db8::1", "ipv6"},
+		{"jane.doe@example.com", "email"},
+		{"1.2.3", "semver"},
+		{"aBcD1234eFgH5678IjKl", "firestoreid"},
+		{"cus_abc123", "id"},
+		{"eyJpZCI6MTIzfQ==", "token"},
+		{"123456", "id"},
+		{"-10456789", "id"},
+		{"150", "id"},
+		{"2024", "slug"},
+		{"42", "slug"},
+		{"12345", "slug"},
+		{"3.14", "float"},
+		{"report.pdf", "filename"},
+		{"my-post-12345", "slug"},
+		{"My-Post-12345", "slug"},
+		{"hello", "slug"},
+		{"Hello World", "param"},
+		{"v1.2", "param"},
+	}
+
+	classifier := NewClassifier()
+	for _, tt := range tests {
+		if got := classifier.classifyParameterType(tt.value); got != tt.expected {
+			t.Errorf("classifyParameterType(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestWithDetectors(t *testing.T) {
+	upper := DetectorFunc(func(segment string) (string, bool) {
+		return "code", segment != "" && strings.ToUpper(segment) == segment
+	})
+
+	classifier := NewClassifier(WithDetectors(upper))
+	if got := classifier.classifyParameterType("ABC"); got != "code" {
+		t.Errorf("classifyParameterType() = %v, want code", got)
+	}
+	// Built-ins are gone
+	if got := classifier.classifyParameterType("d381b052-99eb-40f2-9ede-9bce790faae1"); got != "param" {
+		t.Errorf("classifyParameterType() = %v, want param", got)
+	}
+
+	// Registered types still win
+	classifier.RegisterParameterType("sku", regexp.MustCompile(`^SKU\d+$`))
+	if got := classifier.classifyParameterType("SKU100"); got != "sku" {
+		t.Errorf("classifyParameterType() = %v, want sku", got)
+	}
+}
+
+func TestWithAdditionalDetectors(t *testing.T) {
+	isbn := regexp.MustCompile(`^97[89]\d{10}$`)
+	detector := DetectorFunc(func(segment string) (string, bool) {
+		return "isbn", isbn.MatchString(segment)
+	})
+
+	classifier := NewClassifier(WithAdditionalDetectors(detector))
+
+	// Built-ins come first: a 13-digit number is a timestamp
+	if got := classifier.classifyParameterType("9780306406157"); got != "timestamp" {
+		t.Errorf("classifyParameterType() = %v, want timestamp", got)
+	}
+
+	// but the generic fallbacks come last
+	sku := DetectorFunc(func(segment string) (string, bool) {
+		return "sku", strings.HasPrefix(segment, "sku-")
+	})
+	withSKU := NewClassifier(WithAdditionalDetectors(sku))
+	for _, value := range []string{"sku-red-xl", "sku-catalog.pdf"} {
+		if got := withSKU.classifyParameterType(value); got != "sku" {
+			t.Errorf("classifyParameterType(%q) = %v, want sku", value, got)
+		}
+	}
+	if got := withSKU.classifyParameterType("red-xl"); got != "slug" {
+		t.Errorf("classifyParameterType(%q) = %v, want slug", "red-xl", got)
+	}
+
+	// Registered types are matched before any detector
+	withSKU.RegisterParameterType("item", regexp.MustCompile(`^sku-`))
+	if got := withSKU.classifyParameterType("sku-red-xl"); got != "item" {
+		t.Errorf("classifyParameterType() with a registered type = %v, want item", got)
+	}
+
+	classifier = NewClassifier(WithDetectors(), WithAdditionalDetectors(detector), WithMinDistinctValues(1))
+	classifier.Learn([]string{"/books/9780306406157/reviews", "/books/9780306406157/reviews"})
+
+	// A user detector match counts as evidence for the single-child rule
+	result, _ := classifier.Classify("/books/9780306406157/reviews")
+	if result != "/books/{isbn}/reviews" {
+		t.Errorf("Classify() = %v, want /books/{isbn}/reviews", result)
+	}
+}
+
+func TestWithObjectPrefixes(t *testing.T) {
+	urls := []string{
+		"/accounts/acct_1A2b3C/balance",
+		"/accounts/acct_9Z8y7X/balance",
+		"/accounts/acct_4D5e6F/balance",
+	}
+
+	plain := NewClassifier()
+	if got := plain.classifyParameterType("acct_1A2b3C"); got == "id" {
+		t.Errorf("classifyParameterType() without prefix = %v, want not id", got)
+	}
+
+	classifier := NewClassifier(WithObjectPrefixes("acct", "re", "txn", "seti"), WithImmutableClassify(true))
+	for _, value := range []string{"acct_1A2b3C", "seti_123abc", "cus_abc123"} {
+		if got := classifier.classifyParameterType(value); got != "id" {
+			t.Errorf("classifyParameterType(%q) = %v, want id", value, got)
+		}
+	}
+	classifier.Learn(urls)
+	if result, _ := classifier.Classify("/accounts/acct_7G8h9I/balance"); result != "/accounts/{id}/balance" {
+		t.Errorf("Classify() = %v, want /accounts/{id}/balance", result)
+	}
+	re, err := classifier.PatternRegexp("/accounts/{id}/balance")
+	if err != nil {
+		t.Fatalf("PatternRegexp() error = %v", err)
+	}
+	if !re.MatchString("/accounts/acct_7G8h9I/balance") {
+		t.Errorf("PatternRegexp() = %v, want it to match acct_7G8h9I", re)
+	}
+
+	for _, prefix := range []string{"", "ac-ct", "acct_"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithObjectPrefixes(%q) did not panic", prefix)
+				}
+			}()
+			WithObjectPrefixes(prefix)
+		}()
+	}
+}
+
+func TestDetectorsSurviveSerialization(t *testing.T) {
+	detector := DetectorFunc(func(segment string) (string, bool) {
+		return "ticket", strings.HasPrefix(segment, "TKT")
+	})
+
+	src := NewClassifier(WithAdditionalDetectors(detector))
+	src.Learn([]string{"/tickets/TKT1/view", "/tickets/TKT2/view", "/tickets/TKT3/view"})
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(src); err != nil {
+		t.Fatalf("gob Encode() unexpected error: %v", err)
+	}
+
+	dst := NewClassifier(WithAdditionalDetectors(detector))
+	if err := gob.NewDecoder(&buf).Decode(dst); err != nil {
+		t.Fatalf("gob Decode() unexpected error: %v", err)
+	}
+	result, _ := dst.ClassifyOnly("/tickets/TKT9/view")
+	if result != "/tickets/{ticket}/view" {
+		t.Errorf("ClassifyOnly() = %v, want /tickets/{ticket}/view", result)
+	}
+}
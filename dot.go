@@ -0,0 +1,109 @@
+package classifier
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ToDOT writes the tries as a Graphviz DOT graph for debugging. Nodes are
+// labeled with their value and traversal count (roots have none); end nodes
+// are drawn with a double border, collapsed nodes in blue and pruned nodes
+// in orange. Tries learned with LearnMethod hang off a root labeled with
+// the method.
+//
+// Nodes are emitted breadth-first, so when maxNodes is positive and the trie
+// is larger, the deepest levels are cut and each node with children left out
+// gets a "… N more" node instead. The graph is rendered under the read lock
+// and written after it is released, so a slow w doesn't block learning.
+func (c *Classifier) ToDOT(w io.Writer, maxNodes int) error {
+	var buf bytes.Buffer
+	writeDOTHeader(&buf)
+	c.mu.RLock()
+	c.writeDOT(&buf, maxNodes, "n")
+	c.mu.RUnlock()
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeDOTHeader opens the graph in buf; the caller closes it with "}".
+func writeDOTHeader(buf *bytes.Buffer) {
+	buf.WriteString("digraph trie {\n")
+	buf.WriteString("\tnode [shape=box, style=filled, fillcolor=white];\n")
+}
+
+// writeDOT renders the nodes and edges of the graph into buf, naming nodes
+// by prefix and a counter. Caller must hold at least the read lock.
+func (c *Classifier) writeDOT(buf *bytes.Buffer, maxNodes int, prefix string) {
+	type queued struct {
+		id   int
+		node *Segment
+	}
+
+	var queue []queued
+	nextID := 0
+	emit := func(node *Segment, label string) int {
+		id := nextID
+		nextID++
+		fmt.Fprintf(buf, "\t%s%d [label=%s%s];\n", prefix, id, dotQuote(label), dotStyle(node))
+		queue = append(queue, queued{id, node})
+		return id
+	}
+
+	// Roots aren't traversed, so they carry no count
+	emit(c.root, "/")
+	for _, method := range c.sortedMethods() {
+		emit(c.methods[method], method)
+	}
+
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		keys := make([]string, 0, len(parent.node.children))
+		for key := range parent.node.children {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for i, key := range keys {
+			if maxNodes > 0 && nextID >= maxNodes {
+				fmt.Fprintf(buf, "\t%s%d [label=\"… %d more\", shape=plaintext, style=\"\"];\n", prefix, nextID, len(keys)-i)
+				fmt.Fprintf(buf, "\t%[1]s%[2]d -> %[1]s%[3]d [style=dashed];\n", prefix, parent.id, nextID)
+				nextID++
+				break
+			}
+			child := parent.node.children[key]
+			id := emit(child, fmt.Sprintf("%s (%d)", childLabel(key), child.totalCount.load()))
+			fmt.Fprintf(buf, "\t%[1]s%[2]d -> %[1]s%[3]d;\n", prefix, parent.id, id)
+		}
+	}
+}
+
+// dotEscaper escapes the only characters special in a DOT quoted string.
+// Go's %q would add escapes like \t and \x01 that DOT shows literally.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// dotQuote returns s as a DOT quoted string.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// dotStyle returns the extra DOT attributes marking node's state.
+func dotStyle(node *Segment) string {
+	var attrs string
+	switch {
+	case node.collapsed:
+		attrs += ", fillcolor=lightblue"
+	case node.pruned:
+		attrs += ", fillcolor=orange"
+	}
+	if node.isEnd {
+		attrs += ", peripheries=2"
+	}
+	return attrs
+}
@@ -0,0 +1,72 @@
+package classifier
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToDOT(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{"/api/users", "/api/orders"})
+
+	var buf bytes.Buffer
+	if err := c.ToDOT(&buf, 0); err != nil {
+		t.Fatalf("ToDOT() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"digraph trie {",
+		`n0 [label="/"];`,
+		`n1 [label="api (2)"];`,
+		`n2 [label="orders (1)", peripheries=2];`,
+		`n3 [label="users (1)", peripheries=2];`,
+		"n0 -> n1;",
+		"n1 -> n2;",
+		"n1 -> n3;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ToDOT() output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestToDOTEscaping(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{`/say"hi\`, "/tab\tbed", "/\x01"})
+
+	var buf bytes.Buffer
+	if err := c.ToDOT(&buf, 0); err != nil {
+		t.Fatalf("ToDOT() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`[label="say\"hi\\ (1)"`,
+		"[label=\"tab\tbed (1)\"",
+		"[label=\"\x01 (1)\"",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ToDOT() output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestToDOTMaxNodes(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{"/api/a", "/api/b", "/api/c"})
+
+	var buf bytes.Buffer
+	if err := c.ToDOT(&buf, 3); err != nil {
+		t.Fatalf("ToDOT() error = %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, `label="… 2 more"`) {
+		t.Errorf("ToDOT(3) output missing ellipsis node:\n%s", out)
+	}
+	if strings.Contains(out, `"c (1)"`) {
+		t.Errorf("ToDOT(3) output includes node past the cap:\n%s", out)
+	}
+}
@@ -5,9 +5,53 @@ import "fmt"
 // InsufficientDataError is returned when Classify is called but the classifier
 // has not yet learned enough URLs to produce reliable patterns.
 type InsufficientDataError struct {
-	Count int
+	Count     int // URLs learned so far, including the one being classified if Classify learns
+	Threshold int // MinLearningCount
+}
+
+// Remaining returns how many more URLs must be learned to reach Threshold.
+// When Classify learns, it returns patterns once Threshold is exceeded, so it
+// can still fail with nothing remaining: the next URL classifies.
+func (e *InsufficientDataError) Remaining() int {
+	return max(e.Threshold-e.Count, 0)
 }
 
 func (e *InsufficientDataError) Error() string {
-	return fmt.Sprintf("insufficient data: only %d URLs learned", e.Count)
+	if e.Remaining() == 0 {
+		return fmt.Sprintf("insufficient data: %d/%d URLs learned, classifying from the next URL", e.Count, e.Threshold)
+	}
+	return fmt.Sprintf("insufficient data: %d/%d URLs learned, %d more needed", e.Count, e.Threshold, e.Remaining())
+}
+
+// CollapsedSubtreeError is returned by operations that need exact per-value
+// data, such as Forget and CardinalityAt, when they reach a subtree that was
+// collapsed into a wildcard and so no longer has it. Callers can type-switch
+// on it to tell this apart from a prefix that was never learned.
+type CollapsedSubtreeError struct {
+	Op     string // Operation that failed, e.g. "forget"
+	Prefix string // Path of the node whose children were collapsed
+}
+
+func (e *CollapsedSubtreeError) Error() string {
+	return fmt.Sprintf("%s: subtree at %s is collapsed and per-value data was discarded", e.Op, e.Prefix)
+}
+
+// LearnInterruptedError is returned by LearnContext and LearnReaderContext
+// when their context is done before every URL was learned. The first
+// Learned URLs were kept.
+type LearnInterruptedError struct {
+	Learned int
+	Total   int   // -1 if unknown, as for LearnReaderContext
+	Err     error // the context's error
+}
+
+func (e *LearnInterruptedError) Error() string {
+	if e.Total < 0 {
+		return fmt.Sprintf("learning interrupted after %d URLs: %v", e.Learned, e.Err)
+	}
+	return fmt.Sprintf("learning interrupted after %d of %d URLs: %v", e.Learned, e.Total, e.Err)
+}
+
+func (e *LearnInterruptedError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,93 @@
+package classifier
+
+import "sort"
+
+// evictionCandidate is a subtree that evict may remove.
+type evictionCandidate struct {
+	parent *Segment
+	key    string
+	node   *Segment
+	depth  int
+}
+
+// evict removes the least recently learned subtrees until the trie is back to
+// 90% of MaxNodes, leaving headroom so eviction passes are amortized over
+// many inserts. Nodes on the path of the current insert are never removed.
+// Caller must hold the write lock.
+func (c *Classifier) evict() {
+	parents := make(map[*Segment]*Segment)
+	var candidates []evictionCandidate
+	var collect func(parent *Segment, key string, node *Segment, depth int) int
+	collect = func(parent *Segment, key string, node *Segment, depth int) int {
+		parents[node] = parent
+		if parent != nil && node.lastAccess < c.tick {
+			candidates = append(candidates, evictionCandidate{parent, key, node, depth})
+		}
+		size := 1
+		for childKey, child := range node.children {
+			size += collect(node, childKey, child, depth+1)
+		}
+		return size
+	}
+	c.nodes = 0
+	for _, root := range c.roots() {
+		c.nodes += collect(nil, "", root, 0)
+	}
+	if c.nodes <= c.config.MaxNodes {
+		return
+	}
+
+	// Oldest first; among equally old, the shallowest frees the most at once
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].node.lastAccess != candidates[j].node.lastAccess {
+			return candidates[i].node.lastAccess < candidates[j].node.lastAccess
+		}
+		return candidates[i].depth < candidates[j].depth
+	})
+
+	target := c.config.MaxNodes * 9 / 10
+	removed := make(map[*Segment]bool)
+	for _, cand := range candidates {
+		if c.nodes <= target {
+			break
+		}
+		if removedAncestor(cand.parent, parents, removed) {
+			continue
+		}
+		// Count now rather than during collect: older descendants may
+		// already be gone
+		c.nodes -= c.countNodes(cand.node)
+		delete(cand.parent.children, cand.key)
+		removed[cand.node] = true
+
+		// Ancestors no longer carry the evicted traffic; roots aren't
+		// traversed, so they hold no count
+		evicted := cand.node.totalCount.load()
+		for node := cand.parent; parents[node] != nil; node = parents[node] {
+			node.totalCount.add(-evicted)
+		}
+	}
+
+	// Cached merged children may reference evicted nodes
+	for _, root := range c.roots() {
+		c.clearMerged(root)
+	}
+	c.shape++
+}
+
+// removedAncestor reports whether node or any of its ancestors was evicted.
+func removedAncestor(node *Segment, parents map[*Segment]*Segment, removed map[*Segment]bool) bool {
+	for ; node != nil; node = parents[node] {
+		if removed[node] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Classifier) clearMerged(node *Segment) {
+	node.merged.Store(nil)
+	for _, child := range node.children {
+		c.clearMerged(child)
+	}
+}
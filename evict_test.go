@@ -0,0 +1,85 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMaxNodes(t *testing.T) {
+	classifier := NewClassifier(WithMaxNodes(100))
+
+	for i := 0; i < 5000; i++ {
+		classifier.Learn([]string{fmt.Sprintf("/tenant-%d/dashboard/widgets", i)})
+		if n := classifier.NodeCount(); n > 100 {
+			t.Fatalf("NodeCount() = %d after %d URLs, want at most 100", n, i+1)
+		}
+	}
+
+	if classifier.LearnedCount() != 5000 {
+		t.Errorf("LearnedCount() = %d, want 5000", classifier.LearnedCount())
+	}
+
+	// The most recent route survives eviction; the oldest is gone
+	if classifier.root.children["tenant-4999"] == nil {
+		t.Errorf("most recently learned subtree was evicted")
+	}
+	if classifier.root.children["tenant-0"] != nil {
+		t.Errorf("oldest subtree was not evicted")
+	}
+}
+
+func TestMaxNodesKeepsHotRoutes(t *testing.T) {
+	classifier := NewClassifier(WithMaxNodes(50))
+
+	for i := 0; i < 2000; i++ {
+		classifier.Learn([]string{
+			fmt.Sprintf("/users/%d/profile", 100000+i),
+			fmt.Sprintf("/static-%d/page", i),
+		})
+	}
+
+	result, err := classifier.ClassifyOnly("/users/999999/profile")
+	if err != nil {
+		t.Fatalf("ClassifyOnly() unexpected error: %v", err)
+	}
+	if result != "/users/{id}/profile" {
+		t.Errorf("ClassifyOnly() = %v, want /users/{id}/profile", result)
+	}
+	if n := classifier.NodeCount(); n > 50 {
+		t.Errorf("NodeCount() = %d, want at most 50", n)
+	}
+}
+
+func TestMaxNodesUpdatesAncestorCounts(t *testing.T) {
+	classifier := NewClassifier(WithMaxNodes(50))
+	for i := 0; i < 200; i++ {
+		classifier.Learn([]string{fmt.Sprintf("/app/tenant-%d/home", i)})
+	}
+
+	// Every traversal of a node continues into a child or ends there
+	classifier.walkTrie(func(path string, node *Segment, depth int) bool {
+		if depth == 0 {
+			return true
+		}
+		want := node.endCount.load()
+		for _, child := range node.children {
+			want += child.totalCount.load()
+		}
+		if got := node.totalCount.load(); got != want {
+			t.Errorf("%s totalCount = %d, want %d", path, got, want)
+		}
+		return true
+	})
+}
+
+func TestMaxNodesUnlimitedByDefault(t *testing.T) {
+	classifier := NewClassifier()
+	for i := 0; i < 200; i++ {
+		classifier.Learn([]string{fmt.Sprintf("/tenant-%d/home", i)})
+	}
+
+	// root + 200 tenants + 200 home nodes
+	if n := classifier.NodeCount(); n != 401 {
+		t.Errorf("NodeCount() = %d, want 401", n)
+	}
+}
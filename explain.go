@@ -0,0 +1,187 @@
+package classifier
+
+import "sort"
+
+// DecisionRule identifies why a path segment was kept literal or replaced by
+// a parameter.
+type DecisionRule int
+
+const (
+	// RuleStatic: the segment was learned and its siblings are not variable.
+	RuleStatic DecisionRule = iota
+
+	// RuleUnlearned: the segment was never learned below a static node, so it
+	// is passed through as-is.
+	RuleUnlearned
+
+	// RuleHighVariability: the node has enough children, and their ratio to
+	// traversals meets CardinalityThreshold.
+	RuleHighVariability
+
+	// RuleSingleChildParameter: the node has a single child, seen at least
+	// MinSamples times, that looks like a parameter. Only applies when
+	// MinDistinctValues is 1 or less.
+	RuleSingleChildParameter
+
+	// RuleCollapsed: the node's children were collapsed into a wildcard.
+	RuleCollapsed
+
+	// RuleVariableTail: the segment follows an unseen value under a variable
+	// node with no learned children to match against, so it is parameterized
+	// as well.
+	RuleVariableTail
+
+	// RuleEmbeddedDate: the segment embeds a date (see WithEmbeddedDates).
+	RuleEmbeddedDate
+
+	// RuleTimeout: ClassifyTimeout expired before the segment was reached.
+	RuleTimeout
+
+	// RuleMaxDepth: the segment holds everything past MaxDepth.
+	RuleMaxDepth
+
+	// RuleWildcardTail: the segment holds the rest of a variable-length path
+	// (see WithWildcardTail).
+	RuleWildcardTail
+
+	// RuleShortSegment: the segment is at a variable position but too short
+	// to be a parameter (see WithMinSegmentLengthForParam).
+	RuleShortSegment
+
+	// RuleVersionPrefix: the segment is at a variable position but is an API
+	// version like v1 (see WithStaticVersionPrefix).
+	RuleVersionPrefix
+
+	// RuleOverride: the segment was decided by SegmentOverride (see
+	// WithSegmentOverride).
+	RuleOverride
+)
+
+func (r DecisionRule) String() string {
+	switch r {
+	case RuleStatic:
+		return "static"
+	case RuleUnlearned:
+		return "unlearned"
+	case RuleHighVariability:
+		return "high-variability"
+	case RuleSingleChildParameter:
+		return "single-child-parameter"
+	case RuleCollapsed:
+		return "collapsed"
+	case RuleVariableTail:
+		return "variable-tail"
+	case RuleEmbeddedDate:
+		return "embedded-date"
+	case RuleTimeout:
+		return "timeout"
+	case RuleMaxDepth:
+		return "max-depth"
+	case RuleWildcardTail:
+		return "wildcard-tail"
+	case RuleShortSegment:
+		return "short-segment"
+	case RuleVersionPrefix:
+		return "version-prefix"
+	case RuleOverride:
+		return "override"
+	default:
+		return "unknown"
+	}
+}
+
+// SegmentDecision explains how one path segment was classified. The node
+// statistics describe the node whose children decided the segment; they are
+// zero for unlearned segments. Collapsed and Pruned tell decisions made from
+// retained per-value data apart from those made where it was discarded,
+// which are less trustworthy for rare values.
+type SegmentDecision struct {
+	Raw         string       // Segment as it appeared in the URL
+	Output      string       // Rendered segment, e.g. {id} or the literal
+	Param       bool         // Whether the segment was replaced by a parameter
+	Rule        DecisionRule // Rule that decided the segment
+	Cardinality float64      // Children per traversal of the deciding node
+	TotalCount  int          // Traversals through the deciding node's children
+	ChildCount  int          // Distinct children of the deciding node
+	Samples     []string     // Recent values seen at the position (see WithSampleRetention)
+	Collapsed   bool         // The deciding node's children were collapsed into a wildcard
+	Pruned      bool         // Per-value data at the position was discarded after confirming high cardinality
+}
+
+// Explain reports, segment by segment, why url's path classifies the way it
+// does. It uses the same logic as Classify but never learns. Query strings
+// are not explained.
+func (c *Classifier) Explain(url string) ([]SegmentDecision, error) {
+	if url == "" {
+		return nil, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if count := int(c.learnedCount.Load()); c.config.MinLearningCount > 0 && count < c.config.MinLearningCount {
+		return nil, &InsufficientDataError{Count: count, Threshold: c.config.MinLearningCount}
+	}
+
+	normalized, _, _ := c.normalizeBefore(c.splitURL(url), c.deadline())
+
+	decisions := make([]SegmentDecision, len(normalized))
+	for i, seg := range normalized {
+		decision := SegmentDecision{
+			Raw:    seg.raw,
+			Output: c.formatSegment(seg),
+			Param:  seg.param,
+			Rule:   seg.rule,
+		}
+		if seg.node != nil {
+			for _, child := range seg.node.children {
+				decision.TotalCount += child.totalCount.load()
+			}
+			decision.ChildCount = len(seg.node.children)
+			decision.Collapsed = seg.node.collapsed
+			for _, child := range seg.node.children {
+				decision.Pruned = decision.Pruned || child.pruned
+			}
+			if decision.TotalCount > 0 {
+				decision.Cardinality = float64(decision.ChildCount) / float64(decision.TotalCount)
+			}
+			decision.Samples = c.positionSamples(seg.node)
+		}
+		decisions[i] = decision
+	}
+	return decisions, nil
+}
+
+// positionSamples returns up to SampleRetention distinct values seen below
+// node: the literal children in key order, and the retained samples of a
+// wildcard child.
+func (c *Classifier) positionSamples(node *Segment) []string {
+	n := c.config.SampleRetention
+	if n <= 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(node.children))
+	for key := range node.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var samples []string
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		values := []string{key}
+		if key == "*" || key == mergedWildcardKey {
+			values = node.children[key].recentSamples()
+		}
+		for _, value := range values {
+			if len(samples) == n {
+				return samples
+			}
+			if !seen[value] {
+				seen[value] = true
+				samples = append(samples, value)
+			}
+		}
+	}
+	return samples
+}
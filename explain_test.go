@@ -0,0 +1,142 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	classifier := NewClassifier()
+	classifier.Learn([]string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/api/v1/health",
+	})
+
+	t.Run("high variability", func(t *testing.T) {
+		decisions, err := classifier.Explain("/users/999999/profile")
+		if err != nil {
+			t.Fatalf("Explain() unexpected error: %v", err)
+		}
+
+		want := []struct {
+			raw    string
+			output string
+			rule   DecisionRule
+		}{
+			{"users", "users", RuleStatic},
+			{"999999", "{id}", RuleHighVariability},
+			{"profile", "profile", RuleStatic},
+		}
+		if len(decisions) != len(want) {
+			t.Fatalf("Explain() returned %d decisions, want %d", len(decisions), len(want))
+		}
+		for i, w := range want {
+			d := decisions[i]
+			if d.Raw != w.raw || d.Output != w.output || d.Rule != w.rule {
+				t.Errorf("decision %d = {%q %q %v}, want {%q %q %v}", i, d.Raw, d.Output, d.Rule, w.raw, w.output, w.rule)
+			}
+		}
+
+		id := decisions[1]
+		if !id.Param {
+			t.Errorf("decision 1 Param = false, want true")
+		}
+		if id.ChildCount != 3 || id.TotalCount != 3 || id.Cardinality != 1.0 {
+			t.Errorf("decision 1 stats = {children %d, total %d, cardinality %v}, want {3, 3, 1}",
+				id.ChildCount, id.TotalCount, id.Cardinality)
+		}
+		if id.Collapsed || id.Pruned {
+			t.Errorf("decision 1 Collapsed, Pruned = %v, %v, want false, false", id.Collapsed, id.Pruned)
+		}
+	})
+
+	t.Run("unlearned", func(t *testing.T) {
+		decisions, err := classifier.Explain("/api/v2/health")
+		if err != nil {
+			t.Fatalf("Explain() unexpected error: %v", err)
+		}
+		if len(decisions) != 3 {
+			t.Fatalf("Explain() returned %d decisions, want 3", len(decisions))
+		}
+		if decisions[1].Rule != RuleUnlearned || decisions[1].Param {
+			t.Errorf("decision 1 = %+v, want unlearned literal", decisions[1])
+		}
+	})
+
+	t.Run("matches Classify without learning", func(t *testing.T) {
+		nodes := classifier.NodeCount()
+		decisions, err := classifier.Explain("/users/555555/profile")
+		if err != nil {
+			t.Fatalf("Explain() unexpected error: %v", err)
+		}
+		if classifier.NodeCount() != nodes {
+			t.Errorf("NodeCount() = %d, want %d", classifier.NodeCount(), nodes)
+		}
+
+		got := ""
+		for _, d := range decisions {
+			got += "/" + d.Output
+		}
+		want, _ := classifier.ClassifyOnly("/users/555555/profile")
+		if got != want {
+			t.Errorf("Explain() rendered %v, want %v", got, want)
+		}
+	})
+
+	t.Run("empty url", func(t *testing.T) {
+		decisions, err := classifier.Explain("")
+		if err != nil || decisions != nil {
+			t.Errorf("Explain(\"\") = %v, %v, want nil, nil", decisions, err)
+		}
+	})
+}
+
+func TestExplainCollapsed(t *testing.T) {
+	classifier := NewClassifier(WithMaxValuesPerNode(5), WithPruneHighCardinality(true))
+	for i := range 20 {
+		classifier.Learn([]string{fmt.Sprintf("/users/%d/profile", 100000+i)})
+	}
+
+	decisions, err := classifier.Explain("/users/999999/profile")
+	if err != nil {
+		t.Fatalf("Explain() unexpected error: %v", err)
+	}
+	id := decisions[1]
+	if id.Rule != RuleCollapsed || id.Output != "{id}" {
+		t.Errorf("decision 1 = {%q %v}, want {{id} %v}", id.Output, id.Rule, RuleCollapsed)
+	}
+	if !id.Collapsed || !id.Pruned {
+		t.Errorf("decision 1 Collapsed, Pruned = %v, %v, want true, true", id.Collapsed, id.Pruned)
+	}
+	if decisions[0].Collapsed || decisions[0].Pruned {
+		t.Errorf("decision 0 Collapsed, Pruned = %v, %v, want false, false", decisions[0].Collapsed, decisions[0].Pruned)
+	}
+}
+
+func TestExplainSingleChildParameter(t *testing.T) {
+	classifier := NewClassifier(WithMinDistinctValues(1))
+	classifier.Learn([]string{"/orders/550e8400-e29b-41d4-a716-446655440000", "/orders/550e8400-e29b-41d4-a716-446655440000"})
+
+	decisions, err := classifier.Explain("/orders/550e8400-e29b-41d4-a716-446655440000")
+	if err != nil {
+		t.Fatalf("Explain() unexpected error: %v", err)
+	}
+	if got := decisions[1].Rule; got != RuleSingleChildParameter {
+		t.Errorf("Rule = %v, want %v", got, RuleSingleChildParameter)
+	}
+	if got := decisions[1].Output; got != "{uuid}" {
+		t.Errorf("Output = %v, want {uuid}", got)
+	}
+}
+
+func TestExplainInsufficientData(t *testing.T) {
+	classifier := NewClassifier(WithMinLearningCount(5))
+	classifier.Learn([]string{"/a", "/b"})
+
+	_, err := classifier.Explain("/a")
+	if _, ok := err.(*InsufficientDataError); !ok {
+		t.Errorf("expected *InsufficientDataError, got %v", err)
+	}
+}
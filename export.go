@@ -0,0 +1,94 @@
+package classifier
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PatternStats is one line of ExportStats output.
+type PatternStats struct {
+	Pattern     string  `json:"pattern"`
+	Count       int     `json:"count"`       // Learned URLs that resolved to Pattern
+	Cardinality float64 `json:"cardinality"` // Distinct learned paths per URL, in (0, 1]
+	SampleURL   string  `json:"sample_url"`  // Most common learned path for Pattern
+}
+
+// ExportStats writes one JSON object per learned pattern to w, as newline
+// delimited JSON, sorted by count descending. Paths below collapsed nodes
+// count as one distinct path, represented by their most common value. It
+// holds the read lock, so it can run alongside classification.
+func (c *Classifier) ExportStats(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	byPattern := make(map[string]*patternStats)
+	c.collectPatternStats(byPattern)
+	return writePatternStats(w, byPattern)
+}
+
+// patternStats accumulates a PatternStats line.
+type patternStats struct {
+	PatternStats
+	distinct    int // Distinct learned paths
+	sampleCount int // Learned count of SampleURL
+}
+
+// add counts a distinct learned path, learned count times, keeping it as
+// the sample URL if it is the most common so far.
+func (stats *patternStats) add(path string, count int) {
+	stats.Count += count
+	stats.distinct++
+	if count > stats.sampleCount || (count == stats.sampleCount && path < stats.SampleURL) {
+		stats.SampleURL, stats.sampleCount = path, count
+	}
+}
+
+// collectPatternStats adds the learned patterns to byPattern.
+// Caller must hold at least the read lock.
+func (c *Classifier) collectPatternStats(byPattern map[string]*patternStats) {
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		pattern, _ := c.routePattern(method, parts)
+		stats, exists := byPattern[pattern]
+		if !exists {
+			stats = &patternStats{PatternStats: PatternStats{Pattern: pattern}}
+			byPattern[pattern] = stats
+		}
+		stats.add(samplePath(parts, node), node.endCount.load())
+	})
+}
+
+// writePatternStats writes byPattern to w as ExportStats does.
+func writePatternStats(w io.Writer, byPattern map[string]*patternStats) error {
+	lines := make([]PatternStats, 0, len(byPattern))
+	for _, stats := range byPattern {
+		if stats.Count > 0 {
+			stats.Cardinality = float64(stats.distinct) / float64(stats.Count)
+		}
+		lines = append(lines, stats.PatternStats)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Count != lines[j].Count {
+			return lines[i].Count > lines[j].Count
+		}
+		return lines[i].Pattern < lines[j].Pattern
+	})
+
+	enc := json.NewEncoder(w)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samplePath rebuilds a learned path from the segments leading to node.
+func samplePath(parts []string, node *Segment) string {
+	path := "/" + strings.Join(parts, "/")
+	if node.slashEnd && !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
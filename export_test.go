@@ -0,0 +1,71 @@
+package classifier
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestExportStats(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/users/345678/profile",
+		"/api/v1/health",
+		"/api/v1/health",
+	})
+
+	var buf bytes.Buffer
+	if err := c.ExportStats(&buf); err != nil {
+		t.Fatalf("ExportStats() unexpected error: %v", err)
+	}
+
+	var lines []PatternStats
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line PatternStats
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+
+	expected := []PatternStats{
+		{Pattern: "/users/{id}/profile", Count: 4, Cardinality: 0.75, SampleURL: "/users/345678/profile"},
+		{Pattern: "/api/v1/health", Count: 2, Cardinality: 0.5, SampleURL: "/api/v1/health"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("ExportStats() wrote %d lines, want %d: %+v", len(lines), len(expected), lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], expected[i])
+		}
+	}
+}
+
+func TestExportStatsConcurrent(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{"/users/123456", "/users/789012", "/users/345678"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			if err := c.ExportStats(&buf); err != nil {
+				t.Errorf("ExportStats() unexpected error: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.Classify("/users/111111")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,122 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Forget removes a single learned URL from the trie, undoing one Learn of
+// it: counts along its path are decremented, values that drop to zero are
+// removed, and nodes left unused are deleted. It returns an error if the URL
+// was never learned, or a *CollapsedSubtreeError if its path runs through a
+// collapsed node whose per-value data was discarded and can't be cleanly
+// subtracted. The URL's host, if any, is forgotten as well. Like learning,
+// forgetting is refused while the classifier is frozen.
+func (c *Classifier) Forget(url string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.frozen {
+		return fmt.Errorf("cannot forget %q: classifier is frozen", url)
+	}
+	learned, err := c.unlearn(c.root, c.splitURL(url))
+	if err != nil {
+		return err
+	}
+	if !learned {
+		return fmt.Errorf("cannot forget %q: url has not been learned", url)
+	}
+
+	if c.config.ClassifyQuery {
+		c.forgetQuery(url)
+	}
+	if host, _ := splitHost(url); host != "" {
+		c.forgetHost(host)
+	}
+	c.learnedCount.Add(-1)
+	return nil
+}
+
+// unlearn undoes one insert of parts into the trie at root. It reports
+// false without changing anything if parts weren't learned there, and
+// returns a *CollapsedSubtreeError if they run through a collapsed node.
+// Caller must hold the write lock.
+func (c *Classifier) unlearn(root *Segment, parts []string) (bool, error) {
+	path := make([]*Segment, 1, len(parts)+1)
+	path[0] = root
+
+	node := root
+	for i, part := range parts {
+		if node.collapsed {
+			return false, &CollapsedSubtreeError{Op: "forget", Prefix: "/" + strings.Join(parts[:i], "/")}
+		}
+		child := node.children[part]
+		if child == nil {
+			return false, nil
+		}
+		node = child
+		path = append(path, node)
+	}
+	if !node.isEnd || node.endCount.load() == 0 {
+		return false, nil
+	}
+
+	node.endCount.add(-1)
+	if node.endCount.load() == 0 {
+		node.isEnd = false
+		node.slashEnd = false
+	}
+
+	// Walk back up, removing nodes that no longer carry any traffic
+	for i := len(path) - 1; i >= 1; i-- {
+		seg, parent, part := path[i], path[i-1], parts[i-1]
+		seg.totalCount.add(-1)
+		if cnt, exists := seg.values[part]; exists {
+			if cnt.load() <= 1 {
+				delete(seg.values, part)
+			} else {
+				cnt.add(-1)
+			}
+		}
+		if seg.totalCount.load() <= 0 && len(seg.children) == 0 {
+			delete(parent.children, part)
+			c.nodes--
+		}
+	}
+	for _, seg := range path {
+		seg.merged.Store(nil)
+	}
+	c.shape++
+	return true, nil
+}
+
+// forgetHost undoes learnHost for a single URL. A host learned below a
+// collapsed label keeps its counts, as its labels can't be told apart.
+func (c *Classifier) forgetHost(host string) {
+	labels, _, ok := hostLabels(host)
+	if !ok || c.hosts == nil {
+		return
+	}
+	c.unlearn(c.hosts, labels)
+}
+
+// forgetQuery undoes learnQuery for a single URL.
+func (c *Classifier) forgetQuery(rawURL string) {
+	_, query := splitQuery(rawURL)
+	pairs, _ := queryPairs(query)
+	for _, pair := range pairs {
+		seg := c.queryKeys[pair[0]]
+		if seg == nil {
+			continue
+		}
+		seg.totalCount.add(-1)
+		if cnt := seg.valueCount(pair[1]); cnt <= 1 {
+			delete(seg.values, pair[1])
+		} else {
+			seg.values[pair[1]].add(-1)
+		}
+		if seg.totalCount.load() <= 0 {
+			delete(c.queryKeys, pair[0])
+		}
+	}
+}
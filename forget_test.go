@@ -0,0 +1,121 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestForget(t *testing.T) {
+	t.Run("inverse of a single insert", func(t *testing.T) {
+		c := NewClassifier()
+		c.Learn([]string{"/api/v1/health"})
+		before := c.Stats()
+
+		c.Learn([]string{"/api/v1/legacy/export"})
+		if err := c.Forget("/api/v1/legacy/export"); err != nil {
+			t.Fatalf("Forget() unexpected error: %v", err)
+		}
+
+		after := c.Stats()
+		if after.NodeCount != before.NodeCount {
+			t.Errorf("NodeCount = %d, want %d", after.NodeCount, before.NodeCount)
+		}
+		if after.LearnedCount != before.LearnedCount {
+			t.Errorf("LearnedCount = %d, want %d", after.LearnedCount, before.LearnedCount)
+		}
+		if got := c.root.children["api"].totalCount.load(); got != 1 {
+			t.Errorf("api totalCount = %d, want 1", got)
+		}
+		for _, p := range c.Patterns() {
+			if p == "/api/v1/legacy/export" {
+				t.Error("forgotten pattern still listed in Patterns()")
+			}
+		}
+	})
+
+	t.Run("keeps shared nodes and other occurrences", func(t *testing.T) {
+		c := NewClassifier()
+		c.Learn([]string{"/users/123456", "/users/123456", "/users/123456/profile"})
+
+		if err := c.Forget("/users/123456"); err != nil {
+			t.Fatalf("Forget() unexpected error: %v", err)
+		}
+		node := c.root.children["users"].children["123456"]
+		if node == nil || !node.isEnd || node.endCount.load() != 1 {
+			t.Fatalf("expected /users/123456 to remain with one occurrence")
+		}
+		if node.valueCount("123456") != 2 {
+			t.Errorf("values[123456] = %d, want 2", node.valueCount("123456"))
+		}
+
+		if err := c.Forget("/users/123456"); err != nil {
+			t.Fatalf("Forget() unexpected error: %v", err)
+		}
+		if node.isEnd {
+			t.Error("expected /users/123456 to no longer be an end node")
+		}
+		if c.root.children["users"].children["123456"] == nil {
+			t.Error("node still used by /users/123456/profile was removed")
+		}
+	})
+
+	t.Run("not learned", func(t *testing.T) {
+		c := NewClassifier()
+		c.Learn([]string{"/users/123456/profile"})
+
+		for _, url := range []string{"/users/999999/profile", "/users/123456"} {
+			if err := c.Forget(url); err == nil {
+				t.Errorf("Forget(%q) expected error, got nil", url)
+			}
+		}
+		if c.LearnedCount() != 1 {
+			t.Errorf("LearnedCount = %d, want 1", c.LearnedCount())
+		}
+	})
+
+	t.Run("frees the node budget", func(t *testing.T) {
+		c := NewClassifier(WithMaxNodes(100))
+		c.Learn([]string{"/api/v1/health"})
+		before := c.nodes
+
+		c.Learn([]string{"https://tenant-a.example.com/api/v1/legacy/export"})
+		if err := c.Forget("https://tenant-a.example.com/api/v1/legacy/export"); err != nil {
+			t.Fatalf("Forget() unexpected error: %v", err)
+		}
+		if c.nodes != before+1 { // the host trie's root stays
+			t.Errorf("nodes = %d, want %d", c.nodes, before+1)
+		}
+		if len(c.hosts.children) != 0 {
+			t.Errorf("host trie still has %d labels", len(c.hosts.children))
+		}
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		c := NewClassifier()
+		c.Learn([]string{"/users/123456"})
+		c.Freeze()
+
+		if err := c.Forget("/users/123456"); err == nil {
+			t.Error("Forget() while frozen expected error, got nil")
+		}
+		if c.LearnedCount() != 1 {
+			t.Errorf("LearnedCount = %d, want 1", c.LearnedCount())
+		}
+	})
+
+	t.Run("collapsed subtree", func(t *testing.T) {
+		c := NewClassifier(WithMaxValuesPerNode(3), WithPruneHighCardinality(true))
+		for i := 0; i < 5; i++ {
+			c.Learn([]string{fmt.Sprintf("/users/%08x-0000-4000-8000-%012x/profile", i, i)})
+		}
+
+		err := c.Forget("/users/00000001-0000-4000-8000-000000000001/profile")
+		collapsedErr, ok := err.(*CollapsedSubtreeError)
+		if !ok {
+			t.Fatalf("Forget() error = %v, want *CollapsedSubtreeError", err)
+		}
+		if collapsedErr.Prefix != "/users" {
+			t.Errorf("Prefix = %q, want /users", collapsedErr.Prefix)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package classifier
+
+// Freeze stops the classifier from learning until Unfreeze: Learn and its
+// variants become no-ops, and Classify and its variants classify against
+// the trie as it is, as with WithImmutableClassify, so LearnedCount stays
+// put, and Forget returns an error. Unlike MinLearningCount, which gates
+// output while the model trains, Freeze fixes a trained model in place.
+// Explicit edits such as Merge, Prune, Decay and Reset still apply. The
+// frozen state isn't serialized.
+func (c *Classifier) Freeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = true
+}
+
+// Unfreeze resumes learning after Freeze.
+func (c *Classifier) Unfreeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = false
+}
+
+// Frozen reports whether the classifier is frozen (see Freeze).
+func (c *Classifier) Frozen() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.frozen
+}
@@ -0,0 +1,66 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{"/users/123/profile", "/users/456/profile", "/users/789/profile"})
+	c.Freeze()
+	if !c.Frozen() {
+		t.Fatal("Frozen() = false after Freeze()")
+	}
+
+	learned, nodes := c.LearnedCount(), c.NodeCount()
+	for i := range 100 {
+		url := fmt.Sprintf("/orders/%d/items", 100000+i)
+		if _, err := c.Classify(url); err != nil {
+			t.Fatalf("Classify(%q) unexpected error: %v", url, err)
+		}
+		c.ClassifyMethod("GET", url)
+		c.ClassifyURL("https://tenant-" + fmt.Sprint(i) + ".app.com" + url)
+	}
+	c.Learn([]string{"/orders/1/items"})
+	c.LearnMethod("POST", []string{"/orders"})
+	c.LearnWeighted([]WeightedURL{{URL: "/orders/2/items", Count: 5}})
+	if n, err := c.LearnReader(strings.NewReader("/orders/3/items\n")); n != 0 || err != nil {
+		t.Errorf("LearnReader() = %d, %v while frozen, want 0, nil", n, err)
+	}
+
+	if got := c.LearnedCount(); got != learned {
+		t.Errorf("LearnedCount() = %d after Freeze(), want %d", got, learned)
+	}
+	if got := c.NodeCount(); got != nodes {
+		t.Errorf("NodeCount() = %d after Freeze(), want %d", got, nodes)
+	}
+	if result, _ := c.Classify("/users/999/profile"); result != "/users/{id}/profile" {
+		t.Errorf("Classify() = %v while frozen, want /users/{id}/profile", result)
+	}
+
+	c.Unfreeze()
+	if c.Frozen() {
+		t.Fatal("Frozen() = true after Unfreeze()")
+	}
+	c.Classify("/orders/1/items")
+	if got := c.LearnedCount(); got != learned+1 {
+		t.Errorf("LearnedCount() = %d after Unfreeze(), want %d", got, learned+1)
+	}
+}
+
+func TestFreezeSnapshot(t *testing.T) {
+	c := NewClassifier()
+	c.Freeze()
+	snap := c.Snapshot()
+	snap.Learn([]string{"/api/v1/health"})
+	if !snap.Frozen() || snap.LearnedCount() != 0 {
+		t.Errorf("Snapshot of frozen classifier: Frozen() = %v, LearnedCount() = %d, want true, 0", snap.Frozen(), snap.LearnedCount())
+	}
+
+	c.Unfreeze()
+	if !snap.Frozen() {
+		t.Error("Unfreeze() on the original unfroze the snapshot")
+	}
+}
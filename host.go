@@ -0,0 +1,150 @@
+package classifier
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ClassifyURL is Classify for full URLs that also classifies the host.
+// Hosts are learned label by label from the apex down, in a trie of their
+// own, and labels below the apex that show high cardinality become
+// {subdomain}: https://tenant-abc.app.com/users/123 classifies to
+// {subdomain}.app.com/users/{id}. Ports are kept and IP addresses stay
+// literal. URLs without a host are classified as by Classify.
+func (c *Classifier) ClassifyURL(rawURL string) (string, error) {
+	host, path := splitHost(rawURL)
+	if host == "" {
+		return c.Classify(rawURL)
+	}
+
+	if !c.config.ImmutableClassify {
+		// Known hosts only bump counters, under the read lock
+		c.mu.RLock()
+		learned := c.frozen || c.learnHostFast(host)
+		c.mu.RUnlock()
+		if !learned {
+			c.mu.Lock()
+			if !c.frozen {
+				c.learnHost(host, 1)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	pattern, _, err := c.learnAndClassify("", path)
+	if err != nil || pattern == "" {
+		return pattern, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.classifyHost(host) + pattern, nil
+}
+
+// hostLabels splits host into its labels on ".", apex first, and its port.
+// Labels are learned as they are, without the path normalization of
+// splitPath. ok is false for IP addresses, which aren't classified.
+func hostLabels(host string) (labels []string, port string, ok bool) {
+	name := host
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		name, port = h, p
+	}
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" || net.ParseIP(strings.Trim(name, "[]")) != nil {
+		return nil, "", false
+	}
+
+	labels = strings.Split(name, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	// Keep a leading NUL encoded, reserving it for mergedWildcardKey
+	for i, label := range labels {
+		if strings.HasPrefix(label, "\x00") {
+			labels[i] = "%00" + label[1:]
+		}
+	}
+	return labels, port, true
+}
+
+// learnHost learns host weight times into the host trie, creating it if
+// needed. Caller must hold the write lock.
+func (c *Classifier) learnHost(host string, weight int) {
+	labels, _, ok := hostLabels(host)
+	if !ok {
+		return
+	}
+	if c.hosts == nil {
+		c.hosts = NewSegment("")
+		c.nodes++
+	}
+	c.insertParts(c.hosts, labels, false, weight)
+}
+
+// learnHostFast is learnHost through learnFast, for hosts that are already
+// known. It reports false without changing anything if the write lock is
+// needed. Caller must hold the read lock.
+func (c *Classifier) learnHostFast(host string) bool {
+	labels, _, ok := hostLabels(host)
+	if !ok {
+		return true // nothing to learn
+	}
+	if c.hosts == nil {
+		return false
+	}
+	return c.learnFastParts(c.hosts, labels, false)
+}
+
+// apexLabels returns how many of labels, apex first, form the apex domain,
+// which always stays literal: the registrable domain under an ICANN public
+// suffix, so app.com in tenant-abc.app.com and example.co.uk in
+// api.example.co.uk. Private suffixes such as hosting platforms' are not
+// honored, so tenants of a shared domain still vary below its apex. A host
+// that is itself a public suffix stays literal throughout.
+func apexLabels(labels []string) int {
+	name := make([]string, len(labels))
+	for i, label := range labels {
+		name[len(labels)-1-i] = label
+	}
+	suffix, icann := publicsuffix.PublicSuffix(strings.Join(name, "."))
+	for !icann && strings.Contains(suffix, ".") {
+		_, parent, _ := strings.Cut(suffix, ".")
+		suffix, icann = publicsuffix.PublicSuffix(parent)
+	}
+	return min(strings.Count(suffix, ".")+2, len(labels))
+}
+
+// classifyHost renders host with variable labels below the apex replaced by
+// {subdomain}. Caller must hold at least the read lock.
+func (c *Classifier) classifyHost(host string) string {
+	labels, port, ok := hostLabels(host)
+	if !ok {
+		return host
+	}
+
+	root := c.hosts
+	if root == nil {
+		root = NewSegment("")
+	}
+	normalized, _, _ := c.normalizeSegments(root, labels, time.Time{})
+
+	// Rendered in host order, from the leftmost label to the apex
+	apex := apexLabels(labels)
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		part := label
+		if i >= apex && i < len(normalized) && normalized[i].param {
+			part = c.formatSegment(paramSegment("subdomain"))
+		}
+		parts[len(labels)-1-i] = part
+	}
+
+	result := strings.Join(parts, ".")
+	if port != "" {
+		result += ":" + port
+	}
+	return result
+}
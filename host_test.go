@@ -0,0 +1,124 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClassifier_ClassifyURL(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	var urls []string
+	for i := range 20 {
+		urls = append(urls, fmt.Sprintf("https://tenant-%d.app.com/users/%d", i, i+100))
+	}
+	for i := range 20 {
+		urls = append(urls, fmt.Sprintf("https://api%d.example.co.uk/health", i))
+	}
+	urls = append(urls, "https://www.example.com/about", "https://www.example.com/about")
+	c.Learn(urls)
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://tenant-abc.app.com/users/123", "{subdomain}.app.com/users/{id}"},
+		{"https://tenant-abc.app.com:8443/users/123", "{subdomain}.app.com:8443/users/{id}"},
+		{"https://www.example.com/about", "www.example.com/about"},
+		{"https://app.com/users/123", "app.com/users/{id}"},
+		{"https://api-eu.example.co.uk/health", "{subdomain}.example.co.uk/health"},
+		{"https://example.co.uk/health", "example.co.uk/health"},
+		{"http://10.0.0.1/users/123", "10.0.0.1/users/{id}"},
+		{"/users/123", "/users/{id}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := c.ClassifyURL(tt.url)
+			if err != nil {
+				t.Fatalf("ClassifyURL(%q) error = %v", tt.url, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ClassifyURL(%q) = %q, want %q", tt.url, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApexLabels(t *testing.T) {
+	tests := []struct {
+		host string
+		apex int
+	}{
+		{"tenant-abc.app.com", 2},
+		{"app.com", 2},
+		{"api.example.co.uk", 3},
+		{"a.b.example.com.au", 3},
+		{"tenant.herokuapp.com", 2}, // private suffix
+		{"co.uk", 2},
+		{"localhost", 1},
+		{"svc.internal", 2},
+	}
+	for _, tt := range tests {
+		labels, _, _ := hostLabels(tt.host)
+		if got := apexLabels(labels); got != tt.apex {
+			t.Errorf("apexLabels(%q) = %d, want %d", tt.host, got, tt.apex)
+		}
+	}
+}
+
+func TestClassifier_ClassifyURLLearns(t *testing.T) {
+	c := NewClassifier()
+	var got string
+	for i := range 20 {
+		var err error
+		got, err = c.ClassifyURL(fmt.Sprintf("https://t%d.app.com/health", i))
+		if err != nil {
+			t.Fatalf("ClassifyURL() error = %v", err)
+		}
+	}
+	if want := "{subdomain}.app.com/health"; got != want {
+		t.Errorf("ClassifyURL() = %q, want %q", got, want)
+	}
+
+	// Known hosts are relearned under the read lock
+	c.mu.RLock()
+	fast := c.learnHostFast("t3.app.com")
+	c.mu.RUnlock()
+	if !fast {
+		t.Error("learnHostFast() = false for a known host, want true")
+	}
+	if got := c.hosts.children["com"].children["app"].children["t3"].totalCount.load(); got != 2 {
+		t.Errorf("t3 totalCount = %d, want 2", got)
+	}
+
+	restored := NewClassifier()
+	data, err := c.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if err := restored.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	if n, want := restored.NodeCount(), c.NodeCount(); n != want {
+		t.Errorf("NodeCount() after restore = %d, want %d", n, want)
+	}
+}
+
+func TestClassifier_ClassifyURLHostIgnoresPathOptions(t *testing.T) {
+	c := NewClassifier(WithSeparator("-"), WithMaxDepth(1), WithIndexFiles([]string{"www"}))
+	for i := range 20 {
+		if _, err := c.ClassifyURL(fmt.Sprintf("https://tenant-%d.app.com/health", i)); err != nil {
+			t.Fatalf("ClassifyURL() error = %v", err)
+		}
+	}
+
+	app := c.hosts.children["com"].children["app"]
+	if app == nil || len(app.children) != 20 || app.children["tenant-3"] == nil {
+		t.Fatalf("host trie below app.com = %v, want the 20 tenant-N labels", app)
+	}
+	if got, want := app.children["tenant-3"].totalCount.load(), 1; got != want {
+		t.Errorf("tenant-3 totalCount = %d, want %d", got, want)
+	}
+	if got, err := c.ClassifyURL("https://tenant-abc.app.com/health"); err != nil || got != "{subdomain}.app.com/health" {
+		t.Errorf("ClassifyURL() = %q, %v, want {subdomain}.app.com/health", got, err)
+	}
+}
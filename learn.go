@@ -0,0 +1,134 @@
+package classifier
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+)
+
+// learnChunk is how many URLs LearnContext inserts per write lock
+// acquisition, and so how often it checks for cancellation.
+const learnChunk = 256
+
+// LearnContext is Learn that stops early when ctx is done. The context is
+// checked every few hundred URLs, and the write lock is released between
+// those chunks so a cancelled or long batch never holds it throughout. If
+// ctx ends first, the URLs learned so far are kept and a
+// *LearnInterruptedError wrapping ctx.Err() reports how many there were.
+func (c *Classifier) LearnContext(ctx context.Context, urls []string) error {
+	return c.learnMethodContext(ctx, "", urls)
+}
+
+// learnMethodContext implements LearnContext and LearnMethod, learning into
+// method's trie.
+func (c *Classifier) learnMethodContext(ctx context.Context, method string, urls []string) error {
+	for start := 0; start < len(urls); start += learnChunk {
+		if err := ctx.Err(); err != nil {
+			return &LearnInterruptedError{Learned: start, Total: len(urls), Err: err}
+		}
+
+		end := min(start+learnChunk, len(urls))
+		c.mu.Lock()
+		if c.frozen {
+			c.mu.Unlock()
+			return nil
+		}
+		root := c.learnRoot(method)
+		for _, url := range urls[start:end] {
+			c.insertInto(root, url)
+			c.learnedCount.Add(1)
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// WeightedURL is a URL with the number of times it was seen, as reported by
+// log aggregators.
+type WeightedURL struct {
+	URL   string
+	Count int
+}
+
+// LearnWeighted learns pre-aggregated URLs, each as if Learn had seen it
+// Count times, without replaying them: counts along its path grow by Count
+// in a single insert. The resulting statistics match the expanded Learn,
+// and LearnedCount grows by the total count. Entries with a Count below 1
+// are skipped.
+func (c *Classifier) LearnWeighted(entries []WeightedURL) {
+	for start := 0; start < len(entries); start += learnChunk {
+		end := min(start+learnChunk, len(entries))
+		c.mu.Lock()
+		if c.frozen {
+			c.mu.Unlock()
+			return
+		}
+		for _, entry := range entries[start:end] {
+			if entry.Count < 1 {
+				continue
+			}
+			c.insertWeighted(c.root, entry.URL, entry.Count)
+			c.learnedCount.Add(int64(entry.Count))
+		}
+		c.mu.Unlock()
+	}
+}
+
+// LearnReader learns newline-delimited URLs from r, such as an access log
+// piped in, without materializing them all in memory. Blank lines are
+// skipped. It returns the number of URLs learned; on a read error, those
+// learned before it are kept.
+func (c *Classifier) LearnReader(r io.Reader) (int, error) {
+	return c.LearnReaderContext(context.Background(), r)
+}
+
+// LearnReaderContext is LearnReader that stops when ctx is done, returning
+// the number of URLs learned so far and a *LearnInterruptedError wrapping
+// ctx.Err(), as LearnContext does. The write lock is taken per URL, so
+// readers aren't blocked while waiting on r.
+func (c *Classifier) LearnReaderContext(ctx context.Context, r io.Reader) (int, error) {
+	c.mu.RLock()
+	maxLine := c.config.maxLineLength()
+	c.mu.RUnlock()
+	return learnLines(ctx, r, maxLine, c.learnLine)
+}
+
+// learnLines feeds the non-blank lines of r, trimmed, to learn until it
+// reports false, r is exhausted, or ctx is done. It returns the number of
+// lines learned, and a *LearnInterruptedError if ctx ended first.
+func learnLines(ctx context.Context, r io.Reader, maxLine int, learn func(url string) bool) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(maxLine, 64*1024)), maxLine)
+
+	learned := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return learned, &LearnInterruptedError{Learned: learned, Total: -1, Err: err}
+		}
+
+		url := strings.TrimSpace(scanner.Text())
+		if url == "" {
+			continue
+		}
+
+		if !learn(url) {
+			return learned, nil
+		}
+		learned++
+	}
+	return learned, scanner.Err()
+}
+
+// learnLine learns a single URL under the write lock, reporting false
+// without learning it if the classifier is frozen.
+func (c *Classifier) learnLine(url string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozen {
+		return false
+	}
+	c.insert(url)
+	c.learnedCount.Add(1)
+	return true
+}
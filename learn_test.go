@@ -0,0 +1,210 @@
+package classifier
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLearnReader(t *testing.T) {
+	input := "/users/123456/profile\n\n/users/789012/profile\r\n   \n/users/345678/profile\n"
+
+	c := NewClassifier()
+	n, err := c.LearnReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LearnReader() unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("LearnReader() = %d, want 3", n)
+	}
+	if c.LearnedCount() != 3 {
+		t.Errorf("LearnedCount() = %d, want 3", c.LearnedCount())
+	}
+
+	result, err := c.ClassifyOnly("/users/999999/profile")
+	if err != nil {
+		t.Fatalf("ClassifyOnly() unexpected error: %v", err)
+	}
+	if result != "/users/{id}/profile" {
+		t.Errorf("ClassifyOnly() = %v, want /users/{id}/profile", result)
+	}
+}
+
+func TestLearnReaderLongLines(t *testing.T) {
+	long := "/blobs/" + strings.Repeat("a", 200*1024)
+	input := "/a\n" + long + "\n/b\n"
+
+	c := NewClassifier()
+	n, err := c.LearnReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LearnReader() unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("LearnReader() = %d, want 3", n)
+	}
+
+	small := NewClassifier(WithMaxLineLength(1024))
+	n, err = small.LearnReader(strings.NewReader(input))
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("LearnReader() error = %v, want %v", err, bufio.ErrTooLong)
+	}
+	if n != 1 {
+		t.Errorf("LearnReader() = %d, want 1 learned before the long line", n)
+	}
+}
+
+func TestLearnReaderMaxLineLengthDefault(t *testing.T) {
+	long := "/blobs/" + strings.Repeat("a", 200*1024)
+	input := "/a\n" + long + "\n/b\n"
+
+	for _, n := range []int{0, -1} {
+		c := NewClassifier(WithMaxLineLength(n))
+		learned, err := c.LearnReader(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("WithMaxLineLength(%d): LearnReader() unexpected error: %v", n, err)
+		}
+		if learned != 3 {
+			t.Errorf("WithMaxLineLength(%d): LearnReader() = %d, want 3", n, learned)
+		}
+	}
+}
+
+// cancellingReader returns one line per Read and cancels its context before
+// handing out the last one.
+type cancellingReader struct {
+	lines  []string
+	cancel context.CancelFunc
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	if len(r.lines) == 1 {
+		r.cancel()
+	}
+	return copy(p, line+"\n"), nil
+}
+
+func TestLearnReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewClassifier()
+	r := &cancellingReader{lines: []string{"/a", "/b", "/c"}, cancel: cancel}
+	n, err := c.LearnReaderContext(ctx, r)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("LearnReaderContext() error = %v, want %v", err, context.Canceled)
+	}
+	var interrupted *LearnInterruptedError
+	if !errors.As(err, &interrupted) || interrupted.Learned != n || interrupted.Total != -1 {
+		t.Errorf("LearnReaderContext() error = %#v, want *LearnInterruptedError with Learned %d, Total -1", err, n)
+	}
+	if n >= 3 {
+		t.Errorf("LearnReaderContext() = %d, want fewer than 3 after cancel", n)
+	}
+	if c.LearnedCount() != n {
+		t.Errorf("LearnedCount() = %d, want %d", c.LearnedCount(), n)
+	}
+}
+
+func TestLearnContext(t *testing.T) {
+	urls := make([]string, 1000)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("/users/%d/profile", 100000+i)
+	}
+
+	t.Run("completes", func(t *testing.T) {
+		c := NewClassifier()
+		if err := c.LearnContext(context.Background(), urls); err != nil {
+			t.Fatalf("LearnContext() unexpected error: %v", err)
+		}
+		if c.LearnedCount() != 1000 {
+			t.Errorf("LearnedCount() = %d, want 1000", c.LearnedCount())
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c := NewClassifier()
+		err := c.LearnContext(ctx, urls)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("LearnContext() error = %v, want %v", err, context.Canceled)
+		}
+		var interrupted *LearnInterruptedError
+		if !errors.As(err, &interrupted) {
+			t.Fatalf("expected *LearnInterruptedError, got %T", err)
+		}
+		if interrupted.Learned != c.LearnedCount() || interrupted.Total != 1000 {
+			t.Errorf("LearnInterruptedError = %+v, want Learned %d, Total 1000", interrupted, c.LearnedCount())
+		}
+	})
+
+	t.Run("cancelled midway releases the lock", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := NewClassifier()
+
+		done := make(chan error)
+		go func() { done <- c.LearnContext(ctx, append(urls, urls...)) }()
+		// Readers and writers must still get through while learning runs
+		c.Learn([]string{"/health"})
+		_, _ = c.ClassifyOnly("/health")
+		cancel()
+
+		if err := <-done; err != nil && !errors.Is(err, context.Canceled) {
+			t.Errorf("LearnContext() error = %v", err)
+		}
+	})
+}
+
+func TestLearnWeighted(t *testing.T) {
+	entries := []WeightedURL{
+		{"/users/1001/profile", 3},
+		{"/users/1002/profile", 1},
+		{"/users/1003/settings", 2},
+		{"/health", 50},
+		{"/ignored", 0},
+	}
+
+	weighted := NewClassifier(WithImmutableClassify(true))
+	weighted.LearnWeighted(entries)
+
+	expanded := NewClassifier(WithImmutableClassify(true))
+	for _, entry := range entries {
+		for range entry.Count {
+			expanded.Learn([]string{entry.URL})
+		}
+	}
+
+	if got, want := weighted.LearnedCount(), expanded.LearnedCount(); got != want {
+		t.Errorf("LearnedCount() = %d, want %d", got, want)
+	}
+	if got, want := weighted.Stats(), expanded.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got, want := fmt.Sprint(weighted.PatternCounts()), fmt.Sprint(expanded.PatternCounts()); got != want {
+		t.Errorf("PatternCounts() = %v, want %v", got, want)
+	}
+	for _, prefix := range []string{"/", "/users", "/users/1001"} {
+		gotRatio, gotUnique, _ := weighted.CardinalityAt(prefix)
+		wantRatio, wantUnique, _ := expanded.CardinalityAt(prefix)
+		if gotRatio != wantRatio || gotUnique != wantUnique {
+			t.Errorf("CardinalityAt(%q) = %v, %d, want %v, %d", prefix, gotRatio, gotUnique, wantRatio, wantUnique)
+		}
+	}
+	for _, url := range []string{"/users/1009/profile", "/health"} {
+		got, _ := weighted.Classify(url)
+		want, _ := expanded.Classify(url)
+		if got != want {
+			t.Errorf("Classify(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
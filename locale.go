@@ -0,0 +1,50 @@
+package classifier
+
+import (
+	"regexp"
+	"strings"
+)
+
+// localePattern matches a language subtag optionally followed by a script
+// (zh-Hant) and/or a region (en-US, es-419). Language validity is checked
+// separately against iso639Languages.
+var localePattern = regexp.MustCompile(`^([a-z]{2})(?:[-_][A-Z][a-z]{3})?(?:[-_](?:[A-Za-z]{2}|\d{3}))?$`)
+
+// iso639Languages holds the ISO 639-1 two-letter language codes.
+var iso639Languages = func() map[string]bool {
+	codes := strings.Fields(`
+		aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs ca ce
+		ch co cr cs cu cv cy da de dv dz ee el en eo es et eu fa ff fi fj fo fr
+		fy ga gd gl gn gu gv ha he hi ho hr ht hu hy hz ia id ie ig ii ik io is
+		it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku kv kw ky la lb lg li ln
+		lo lt lu lv mg mh mi mk ml mn mr ms mt my na nb nd ne ng nl nn no nr nv
+		ny oc oj om or os pa pi pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk
+		sl sm sn so sq sr ss st su sv sw ta te tg th ti tk tl tn to tr ts tt tw
+		ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu`)
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}()
+
+// isLocale reports whether value is an ISO 639-1 language code or a
+// lang-REGION locale such as en-US, pt_BR or zh-Hant-TW.
+func isLocale(value string) bool {
+	m := localePattern.FindStringSubmatch(value)
+	return m != nil && iso639Languages[m[1]]
+}
+
+// localeChildren reports whether every child of node is a locale code, as
+// under the root of /en-US/docs and /fr/support.
+func localeChildren(node *Segment) bool {
+	if len(node.children) == 0 {
+		return false
+	}
+	for value := range node.children {
+		if !isLocale(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package classifier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// paramPlaceholder matches a {type} parameter in a braces-format pattern.
+var paramPlaceholder = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// paramExpressions maps built-in parameter types to the expression a
+// concrete value matches. Types without an entry match any single segment.
+var paramExpressions = map[string]string{
+	"uuid":        unanchored(uuidPattern),
+	"date":        unanchored(datePattern),
+	"datetime":    unanchored(datetimePattern),
+	"timestamp":   unanchored(timestampPattern),
+	"objectid":    unanchored(objectIDPattern),
+	"hash":        unanchored(hashPattern),
+	"ulid":        unanchored(ulidPattern),
+	"ipv4":        `\d{1,3}(?:\.\d{1,3}){3}`,
+	"ipv6":        `[0-9A-Fa-f:.]*:[0-9A-Fa-f:.]*`,
+	"email":       unanchored(emailPattern),
+	"semver":      unanchored(semverPattern),
+	"locale":      unanchored(localePattern),
+	"firestoreid": unanchored(firestoreIDPattern),
+	"token":       unanchored(tokenPattern),
+	"float":       unanchored(decimalPattern),
+	"filename":    unanchored(filenamePattern),
+	"name":        `[\w-]+(?:\.[\w-]+)*`, // filename stem with WithPreserveExtension
+	"slug":        unanchored(slugPattern),
+	"*":           `.+`,
+}
+
+// unanchored returns the expression of a ^...$ detection pattern.
+func unanchored(re *regexp.Regexp) string {
+	return strings.TrimSuffix(strings.TrimPrefix(re.String(), "^"), "$")
+}
+
+// PatternRegexp compiles a learned pattern such as /users/{id}/profile into
+// an anchored regular expression matching the concrete URL paths it stands
+// for, so traffic can be bucketed without the trie. Each parameter becomes a
+// named capture group named like the keys of ClassifyWithParams: the
+// parameter label, numbered when repeated (id, id2). {*} is captured as
+// path. Labels renamed by ParameterNames and registered custom types are
+// understood. Patterns must be in the braces format and start with "/".
+func (c *Classifier) PatternRegexp(pattern string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("pattern %q must start with /", pattern)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	labels := make(map[string]string, len(c.config.ParameterNames))
+	for paramType, label := range c.config.ParameterNames {
+		labels[label] = paramType
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	seen := make(map[string]int)
+	last := 0
+	for _, loc := range paramPlaceholder.FindAllStringSubmatchIndex(pattern, -1) {
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		last = loc[1]
+
+		label := pattern[loc[2]:loc[3]]
+		paramType := label
+		if t, ok := labels[label]; ok {
+			paramType = t
+		}
+
+		name := captureName(label)
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			name += strconv.Itoa(n)
+		}
+		fmt.Fprintf(&expr, "(?P<%s>%s)", name, c.paramExpression(paramType))
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// paramExpression returns the expression matching values of paramType.
+// Caller must hold at least the read lock.
+func (c *Classifier) paramExpression(paramType string) string {
+	for _, ct := range c.customTypes {
+		if ct.name == paramType {
+			return unanchored(ct.pattern)
+		}
+	}
+	if paramType == "id" {
+		return `[+-]?\d+|` + unanchored(c.prefixedID)
+	}
+	if expr, ok := paramExpressions[paramType]; ok {
+		return expr
+	}
+	return `[^/]+`
+}
+
+// captureName turns a parameter label into a valid capture group name.
+func captureName(label string) string {
+	if label == "*" {
+		return "path"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, label)
+}
@@ -0,0 +1,102 @@
+package classifier
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestPatternRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{
+			pattern: "/users/{id}/profile",
+			match:   []string{"/users/123456/profile", "/users/cus_abc123/profile"},
+			noMatch: []string{"/users/abc/profile", "/users/123456/profile/edit", "/users/123456"},
+		},
+		{
+			pattern: "/orders/{uuid}",
+			match:   []string{"/orders/d381b052-99eb-40f2-9ede-9bce790faae1"},
+			noMatch: []string{"/orders/123456", "/orders/d381b052-99eb-40f2-9ede-9bce790faae1/items"},
+		},
+		{
+			pattern: "/blog/{slug}",
+			match:   []string{"/blog/my-post-12345", "/blog/hello"},
+			noMatch: []string{"/blog/Hello World", "/blog/a/b"},
+		},
+		{
+			pattern: "/backups/backup-{date}.tar.gz",
+			match:   []string{"/backups/backup-2024-01-15.tar.gz"},
+			noMatch: []string{"/backups/backup-2024-01-15xtar.gz"},
+		},
+		{
+			pattern: "/files/{*}",
+			match:   []string{"/files/a/b/c.txt"},
+			noMatch: []string{"/files/"},
+		},
+		{
+			pattern: "/search/{param}",
+			match:   []string{"/search/anything goes"},
+			noMatch: []string{"/search/a/b"},
+		},
+	}
+
+	c := NewClassifier()
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := c.PatternRegexp(tt.pattern)
+			if err != nil {
+				t.Fatalf("PatternRegexp() unexpected error: %v", err)
+			}
+			for _, url := range tt.match {
+				if !re.MatchString(url) {
+					t.Errorf("%v does not match %q", re, url)
+				}
+			}
+			for _, url := range tt.noMatch {
+				if re.MatchString(url) {
+					t.Errorf("%v matches %q", re, url)
+				}
+			}
+		})
+	}
+}
+
+func TestPatternRegexpCaptures(t *testing.T) {
+	c := NewClassifier(WithParameterNames(map[string]string{"uuid": "orgId"}))
+	c.RegisterParameterType("sku", regexp.MustCompile(`^SKU\d+$`))
+
+	re, err := c.PatternRegexp("/orgs/{orgId}/users/{id}/posts/{id}/items/{sku}")
+	if err != nil {
+		t.Fatalf("PatternRegexp() unexpected error: %v", err)
+	}
+
+	m := re.FindStringSubmatch("/orgs/d381b052-99eb-40f2-9ede-9bce790faae1/users/123456/posts/789012/items/SKU42")
+	if m == nil {
+		t.Fatalf("%v does not match", re)
+	}
+	expected := map[string]string{
+		"orgId": "d381b052-99eb-40f2-9ede-9bce790faae1",
+		"id":    "123456",
+		"id2":   "789012",
+		"sku":   "SKU42",
+	}
+	for name, value := range expected {
+		if got := m[re.SubexpIndex(name)]; got != value {
+			t.Errorf("capture %s = %q, want %q", name, got, value)
+		}
+	}
+
+	if re.MatchString("/orgs/not-a-uuid/users/123456/posts/789012/items/SKU42") {
+		t.Errorf("%v matches a non-UUID orgId", re)
+	}
+}
+
+func TestPatternRegexpInvalid(t *testing.T) {
+	c := NewClassifier()
+	if _, err := c.PatternRegexp("users/{id}"); err == nil {
+		t.Error("PatternRegexp() expected error for a relative pattern")
+	}
+}
@@ -0,0 +1,71 @@
+package classifier
+
+import "unsafe"
+
+// Sizes used to estimate the heap footprint of the trie. They come from the
+// actual types so the estimate follows changes to Segment.
+const (
+	segmentSize   = int64(unsafe.Sizeof(Segment{}))
+	childSlotSize = int64(unsafe.Sizeof("") + unsafe.Sizeof((*Segment)(nil)))
+	valueSlotSize = int64(unsafe.Sizeof("") + unsafe.Sizeof((*counter)(nil)))
+	counterSize   = int64(unsafe.Sizeof(counter{}))
+
+	mapHeaderSize = 48 // runtime map header
+	mapGroupSlots = 8  // slots per map group, each with a one-byte control word
+)
+
+// MemoryUsage estimates the heap memory held by the learned tries and query
+// statistics, in bytes. It accounts for each Segment struct, its maps (sized
+// by the runtime's group layout and maximum load factor) and string data,
+// but not for cached merged views, which are rebuilt on demand.
+func (c *Classifier) MemoryUsage() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var total int64
+	for _, root := range c.roots() {
+		total += subtreeMemory(root)
+	}
+	for _, seg := range c.queryKeys {
+		total += subtreeMemory(seg)
+	}
+	return total
+}
+
+func subtreeMemory(s *Segment) int64 {
+	size := segmentMemory(s)
+	for _, child := range s.children {
+		size += subtreeMemory(child)
+	}
+	return size
+}
+
+// segmentMemory estimates the memory held by s itself. Child keys share
+// their string data with the child's value, which is counted at the child.
+func segmentMemory(s *Segment) int64 {
+	size := segmentSize + int64(len(s.value))
+	size += mapMemory(len(s.children), childSlotSize)
+	size += mapMemory(len(s.values), valueSlotSize)
+	size += int64(len(s.values)) * counterSize
+	for value := range s.values {
+		if value != s.value {
+			size += int64(len(value))
+		}
+	}
+	// Samples usually share string data with values, so only the ring counts
+	size += int64(cap(s.samples)) * int64(unsafe.Sizeof(""))
+	return size
+}
+
+// mapMemory estimates the size of a map holding n entries of slotSize bytes.
+// Maps grow in groups of mapGroupSlots slots and are kept at most 7/8 full.
+func mapMemory(n int, slotSize int64) int64 {
+	if n == 0 {
+		return mapHeaderSize
+	}
+	groups := int64(1)
+	if n > mapGroupSlots {
+		groups = int64((n*8/7 + mapGroupSlots - 1) / mapGroupSlots)
+	}
+	return mapHeaderSize + groups*mapGroupSlots*(slotSize+1)
+}
@@ -0,0 +1,148 @@
+package classifier
+
+// MergeStrategy determines how Merge resolves a node that is collapsed into
+// a wildcard on one side and still holds individual children on the other.
+type MergeStrategy int
+
+const (
+	// PreferStructured keeps the non-collapsed side's children. The collapsed
+	// side's wildcard subtree is discarded since it can't be split back into
+	// per-value children; its counts are still added to the parent node.
+	PreferStructured MergeStrategy = iota
+
+	// PreferCollapsed collapses the structured side as well and merges the
+	// two wildcard subtrees.
+	PreferCollapsed
+
+	// SumOnly merges children and stats as-is and clears the collapsed flag,
+	// leaving the next insert to re-decide whether the node should collapse.
+	// The collapsed side's wildcard subtree is kept apart from the literal
+	// children, so a literal * segment doesn't match it.
+	SumOnly
+)
+
+// mergedWildcardKey is the child key under which SumOnly keeps the wildcard
+// subtree of a collapsed side next to the other side's literal children.
+// splitPath never yields a segment starting with NUL, so it can't collide
+// with a learned segment, not even a literal *.
+const mergedWildcardKey = "\x00*"
+
+// childLabel returns the key of a child as shown to users, * for
+// mergedWildcardKey.
+func childLabel(key string) string {
+	if key == mergedWildcardKey {
+		return "*"
+	}
+	return key
+}
+
+// Merge folds the learned state of other into c, so classifiers trained on
+// separate shards can be combined. Counts and values are summed; conflicts
+// between collapsed and structured nodes are resolved using c's MergeStrategy.
+// other is not modified.
+func (c *Classifier) Merge(other *Classifier) {
+	other.mu.RLock()
+	src := other.root.clone()
+	srcMethods := make(map[string]*Segment, len(other.methods))
+	for method, root := range other.methods {
+		srcMethods[method] = root.clone()
+	}
+	var srcHosts *Segment
+	if other.hosts != nil {
+		srcHosts = other.hosts.clone()
+	}
+	srcLearned := other.learnedCount.Load()
+	srcQuery := make(map[string]*Segment, len(other.queryKeys))
+	for key, seg := range other.queryKeys {
+		srcQuery[key] = seg.clone()
+	}
+	other.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mergeSegment(c.root, src)
+	for method, root := range srcMethods {
+		if dst, exists := c.methods[method]; exists {
+			c.mergeSegment(dst, root)
+		} else {
+			if c.methods == nil {
+				c.methods = make(map[string]*Segment)
+			}
+			c.methods[method] = root
+		}
+	}
+	if c.hosts == nil {
+		c.hosts = srcHosts
+	} else if srcHosts != nil {
+		c.mergeSegment(c.hosts, srcHosts)
+	}
+	c.learnedCount.Add(srcLearned)
+	c.nodes = c.countAllNodes()
+	c.shape++
+	for key, seg := range srcQuery {
+		if dst, exists := c.queryKeys[key]; exists {
+			c.mergeSegment(dst, seg)
+		} else {
+			c.queryKeys[key] = seg
+		}
+	}
+}
+
+// mergeSegment merges src into dst. src must not be shared with any other
+// trie since its nodes may be adopted into dst.
+func (c *Classifier) mergeSegment(dst, src *Segment) {
+	dst.merged.Store(nil)
+	dst.totalCount.add(src.totalCount.load())
+	dst.lastAccess = max(dst.lastAccess, src.lastAccess)
+	dst.absorbEnd(src)
+	dst.pruned = dst.pruned || src.pruned
+	if src.uniqueCount > dst.uniqueCount {
+		dst.uniqueCount = src.uniqueCount
+	}
+	for v, cnt := range src.values {
+		if _, exists := dst.values[v]; exists ||
+			c.config.MaxValuesPerNode == 0 || len(dst.values) < c.config.MaxValuesPerNode {
+			dst.addValue(v, cnt.load())
+		}
+	}
+	for _, sample := range src.recentSamples() {
+		dst.addSample(sample, c.config.SampleRetention)
+	}
+
+	if dst.collapsed != src.collapsed && len(dst.children) > 0 && len(src.children) > 0 {
+		switch c.config.MergeStrategy {
+		case PreferCollapsed:
+			if dst.collapsed {
+				c.collapseChildren(src)
+			} else {
+				c.collapseChildren(dst)
+			}
+		case SumOnly:
+			// The wildcard moves aside so literal * segments don't reach it
+			for _, side := range []*Segment{dst, src} {
+				if side.collapsed {
+					side.children[mergedWildcardKey] = side.children["*"]
+					delete(side.children, "*")
+					side.collapsed = false
+				}
+			}
+		default: // PreferStructured
+			if dst.collapsed {
+				dst.children = src.children
+				dst.collapsed = false
+			}
+			return
+		}
+	} else if src.collapsed {
+		dst.collapsed = true
+	}
+
+	for name, srcChild := range src.children {
+		if dstChild, exists := dst.children[name]; exists {
+			c.mergeSegment(dstChild, srcChild)
+		} else {
+			dst.children[name] = srcChild
+		}
+	}
+}
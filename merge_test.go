@@ -0,0 +1,129 @@
+package classifier
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	uuidURLs := func(start, n int) []string {
+		urls := make([]string, n)
+		for i := 0; i < n; i++ {
+			urls[i] = fmt.Sprintf("/users/%08x-0000-4000-8000-%012x/profile", start+i, start+i)
+		}
+		return urls
+	}
+
+	// newShards returns a classifier whose /users node is collapsed and one
+	// whose /users node still holds individual children.
+	newShards := func(strategy MergeStrategy) (*Classifier, *Classifier) {
+		collapsed := NewClassifier(
+			WithMaxValuesPerNode(3),
+			WithPruneHighCardinality(true),
+			WithMergeStrategy(strategy),
+		)
+		collapsed.Learn(uuidURLs(0, 5))
+
+		structured := NewClassifier(WithMergeStrategy(strategy))
+		structured.Learn(uuidURLs(100, 4))
+
+		if !collapsed.root.children["users"].collapsed {
+			t.Fatal("expected collapsed shard to have a collapsed /users node")
+		}
+		return collapsed, structured
+	}
+
+	t.Run("sums learned counts", func(t *testing.T) {
+		a := NewClassifier()
+		a.Learn([]string{"/api/v1/health", "/api/v1/health"})
+		b := NewClassifier()
+		b.Learn([]string{"/api/v1/health", "/api/v1/status"})
+
+		a.Merge(b)
+
+		if a.LearnedCount() != 4 {
+			t.Errorf("LearnedCount = %d, want 4", a.LearnedCount())
+		}
+		if got := a.root.children["api"].totalCount.load(); got != 4 {
+			t.Errorf("api totalCount = %d, want 4", got)
+		}
+		if b.LearnedCount() != 2 {
+			t.Errorf("source LearnedCount = %d, want 2 (unmodified)", b.LearnedCount())
+		}
+	})
+
+	t.Run("PreferStructured keeps the structured side", func(t *testing.T) {
+		collapsed, structured := newShards(PreferStructured)
+		collapsed.Merge(structured)
+
+		users := collapsed.root.children["users"]
+		if users.collapsed {
+			t.Error("expected /users to be structured after merge")
+		}
+		if len(users.children) != 4 {
+			t.Errorf("len(children) = %d, want 4", len(users.children))
+		}
+		if users.totalCount.load() != 9 {
+			t.Errorf("totalCount = %d, want 9", users.totalCount.load())
+		}
+
+		result, _ := collapsed.Classify(uuidURLs(500, 1)[0])
+		if result != "/users/{uuid}/profile" {
+			t.Errorf("Classify() = %v, want /users/{uuid}/profile", result)
+		}
+	})
+
+	t.Run("PreferCollapsed collapses both sides", func(t *testing.T) {
+		collapsed, structured := newShards(PreferCollapsed)
+		structured.Merge(collapsed)
+
+		users := structured.root.children["users"]
+		if !users.collapsed {
+			t.Error("expected /users to be collapsed after merge")
+		}
+		if len(users.children) != 1 || users.children["*"] == nil {
+			t.Fatalf("expected a single wildcard child, got %d children", len(users.children))
+		}
+		if users.children["*"].totalCount.load() != 9 {
+			t.Errorf("wildcard totalCount = %d, want 9", users.children["*"].totalCount.load())
+		}
+
+		result, _ := structured.Classify(uuidURLs(500, 1)[0])
+		if result != "/users/{uuid}/profile" {
+			t.Errorf("Classify() = %v, want /users/{uuid}/profile", result)
+		}
+	})
+
+	t.Run("SumOnly keeps both and clears collapsed", func(t *testing.T) {
+		collapsed, structured := newShards(SumOnly)
+		structured.Merge(collapsed)
+
+		users := structured.root.children["users"]
+		if users.collapsed {
+			t.Error("expected /users collapsed flag to be cleared")
+		}
+		wildcard := users.children[mergedWildcardKey]
+		if wildcard == nil || users.children["*"] != nil {
+			t.Fatal("expected wildcard child to be carried over under mergedWildcardKey")
+		}
+		if len(users.children) != 5 {
+			t.Errorf("len(children) = %d, want 5", len(users.children))
+		}
+
+		// A literal * segment gets a child of its own
+		structured.Learn([]string{"/users/*/profile"})
+		if literal := users.children["*"]; literal == nil || literal.totalCount.load() != 1 {
+			t.Error("expected a literal * segment to be learned apart from the wildcard")
+		}
+		if got := wildcard.totalCount.load(); got != 5 {
+			t.Errorf("wildcard totalCount = %d, want 5", got)
+		}
+		structured.Walk(func(path string, info SegmentInfo) bool {
+			if strings.Contains(path, mergedWildcardKey) {
+				t.Errorf("Walk() path %q exposes mergedWildcardKey", path)
+			}
+			return true
+		})
+	})
+}
@@ -0,0 +1,95 @@
+package classifier
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// ClassifyMethod is Classify for a request with the given HTTP method. Each
+// method learns into its own trie, so GET /users/123 and DELETE /users/123
+// are separate routes, and the pattern is prefixed with the method:
+// "GET /users/{id}". Methods are case-insensitive. An empty method is the
+// same as Classify.
+func (c *Classifier) ClassifyMethod(method, url string) (string, error) {
+	method = normalizeMethod(method)
+	pattern, _, err := c.learnAndClassify(method, url)
+	if err != nil || pattern == "" || method == "" {
+		return pattern, err
+	}
+	return method + " " + pattern, nil
+}
+
+// LearnMethod is Learn for requests with the given HTTP method (see
+// ClassifyMethod). URLs learned without a method are unaffected.
+func (c *Classifier) LearnMethod(method string, urls []string) {
+	_ = c.learnMethodContext(context.Background(), normalizeMethod(method), urls)
+}
+
+func normalizeMethod(method string) string {
+	return strings.ToUpper(strings.TrimSpace(method))
+}
+
+// methodRoot returns the trie for method, or an empty one if nothing was
+// learned for it. Caller must hold at least the read lock.
+func (c *Classifier) methodRoot(method string) *Segment {
+	if method == "" {
+		return c.root
+	}
+	if root, ok := c.methods[method]; ok {
+		return root
+	}
+	return NewSegment("")
+}
+
+// learnRoot returns the trie for method, creating it if needed. Caller must
+// hold the write lock.
+func (c *Classifier) learnRoot(method string) *Segment {
+	if method == "" {
+		return c.root
+	}
+	root, ok := c.methods[method]
+	if !ok {
+		if c.methods == nil {
+			c.methods = make(map[string]*Segment)
+		}
+		root = NewSegment("")
+		c.methods[method] = root
+		c.nodes++
+	}
+	return root
+}
+
+// sortedMethods returns the methods with their own trie, sorted.
+func (c *Classifier) sortedMethods() []string {
+	methods := make([]string, 0, len(c.methods))
+	for method := range c.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// roots returns every trie root: the method-less root first, then one per
+// method in sorted order, then the host trie if any. Caller must hold at
+// least the read lock.
+func (c *Classifier) roots() []*Segment {
+	roots := []*Segment{c.root}
+	for _, method := range c.sortedMethods() {
+		roots = append(roots, c.methods[method])
+	}
+	if c.hosts != nil {
+		roots = append(roots, c.hosts)
+	}
+	return roots
+}
+
+// countAllNodes counts the nodes of every trie. Caller must hold at least the
+// read lock.
+func (c *Classifier) countAllNodes() int {
+	total := 0
+	for _, root := range c.roots() {
+		total += c.countNodes(root)
+	}
+	return total
+}
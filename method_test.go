@@ -0,0 +1,123 @@
+package classifier
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyMethod(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.LearnMethod("GET", []string{"/users/123456", "/users/234567", "/users/345678"})
+	c.LearnMethod("delete", []string{"/users/123456"})
+	c.Learn([]string{"/health"})
+
+	tests := []struct {
+		method   string
+		url      string
+		expected string
+	}{
+		{"GET", "/users/999999", "GET /users/{id}"},
+		{"get", "/users/999999", "GET /users/{id}"},
+		{"DELETE", "/users/123456", "DELETE /users/123456"},
+		{"POST", "/users/999999", "POST /users/999999"},
+		{"", "/health", "/health"},
+		{"", "/users/999999", "/users/999999"},
+	}
+
+	for _, tt := range tests {
+		result, err := c.ClassifyMethod(tt.method, tt.url)
+		if err != nil {
+			t.Fatalf("ClassifyMethod(%q, %q) unexpected error: %v", tt.method, tt.url, err)
+		}
+		if result != tt.expected {
+			t.Errorf("ClassifyMethod(%q, %q) = %v, want %v", tt.method, tt.url, result, tt.expected)
+		}
+	}
+
+	if got := c.LearnedCount(); got != 5 {
+		t.Errorf("LearnedCount() = %d, want 5", got)
+	}
+
+	expected := []string{"/health", "DELETE /users/123456", "GET /users/{id}"}
+	if patterns := c.Patterns(); !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("Patterns() = %v, want %v", patterns, expected)
+	}
+}
+
+func TestClassifyMethodLearns(t *testing.T) {
+	c := NewClassifier()
+	for _, url := range []string{"/orders/123456", "/orders/234567", "/orders/345678"} {
+		c.ClassifyMethod("POST", url)
+	}
+
+	result, _ := c.ClassifyMethod("POST", "/orders/456789")
+	if result != "POST /orders/{id}" {
+		t.Errorf("ClassifyMethod() = %v, want POST /orders/{id}", result)
+	}
+	result, _ = c.ClassifyOnly("/orders/456789")
+	if result != "/orders/456789" {
+		t.Errorf("ClassifyOnly() = %v, want /orders/456789", result)
+	}
+}
+
+func TestMethodsPersist(t *testing.T) {
+	src := NewClassifier()
+	src.LearnMethod("GET", []string{"/users/123456", "/users/234567", "/users/345678"})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(src)
+		if err != nil {
+			t.Fatalf("Marshal() unexpected error: %v", err)
+		}
+		dst := NewClassifier()
+		if err := json.Unmarshal(data, dst); err != nil {
+			t.Fatalf("Unmarshal() unexpected error: %v", err)
+		}
+		if got, want := dst.NodeCount(), src.NodeCount(); got != want {
+			t.Errorf("NodeCount() = %d, want %d", got, want)
+		}
+		if result, _ := dst.ClassifyMethod("GET", "/users/999999"); result != "GET /users/{id}" {
+			t.Errorf("ClassifyMethod() = %v, want GET /users/{id}", result)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		dst := NewClassifier(WithImmutableClassify(true))
+		dst.LearnMethod("PUT", []string{"/users/123456"})
+		dst.Merge(src)
+		if result, _ := dst.ClassifyMethod("GET", "/users/999999"); result != "GET /users/{id}" {
+			t.Errorf("ClassifyMethod() = %v, want GET /users/{id}", result)
+		}
+		if result, _ := dst.ClassifyMethod("PUT", "/users/123456"); result != "PUT /users/123456" {
+			t.Errorf("ClassifyMethod() = %v, want PUT /users/123456", result)
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		snap := src.Snapshot()
+		src.LearnMethod("GET", []string{"/accounts/1"})
+		if patterns := snap.Patterns(); !reflect.DeepEqual(patterns, []string{"GET /users/{id}"}) {
+			t.Errorf("Snapshot().Patterns() = %v, want [GET /users/{id}]", patterns)
+		}
+	})
+}
+
+func TestToOpenAPIPathsMethods(t *testing.T) {
+	c := NewClassifier()
+	urls := []string{"/users/123456", "/users/234567", "/users/345678"}
+	c.LearnMethod("GET", urls)
+	c.LearnMethod("DELETE", urls)
+	c.LearnMethod("PURGE", urls)
+
+	item, ok := c.ToOpenAPIPaths()["/users/{id}"]
+	if !ok {
+		t.Fatalf("ToOpenAPIPaths() missing /users/{id}")
+	}
+	if item.Get == nil || item.Delete == nil {
+		t.Errorf("path item = %+v, want get and delete operations", item)
+	}
+	if item.Post != nil || item.Put != nil {
+		t.Errorf("path item = %+v, want only get and delete operations", item)
+	}
+}
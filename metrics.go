@@ -1,41 +1,164 @@
 package classifier
 
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
 // Stats contains aggregate statistics about the classifier state.
 type Stats struct {
-	LearnedCount   int   // Total URLs learned
-	NodeCount      int   // Total nodes in the trie
-	MaxDepth       int   // Maximum depth of the trie
-	MemoryEstimate int64 // Estimated memory usage in bytes
-	UniqueValues   int   // Total unique values across all nodes
-	PrunedNodes    int   // Nodes with values cleared (high cardinality confirmed)
-	CollapsedNodes int   // Nodes with children collapsed to wildcard
+	LearnedCount     int   // Total URLs learned
+	NodeCount        int   // Total nodes in the trie
+	MaxDepth         int   // Maximum depth of the trie
+	MemoryEstimate   int64 // Estimated memory usage of the tries in bytes (see MemoryUsage)
+	UniqueValues     int   // Total unique values across all nodes
+	PrunedNodes      int   // Nodes with values cleared (high cardinality confirmed)
+	CollapsedNodes   int   // Nodes with children collapsed to wildcard
+	Timeouts         int64 // Classify calls that hit ClassifyTimeout
+	DistinctPatterns int   // Distinct normalized patterns (see DistinctPatternCount)
 }
 
 // Stats returns aggregate statistics about the classifier's current state.
+// DistinctPatterns is only recounted once routes are added or removed, nodes
+// collapse, or the thresholds change, so it can lag changes that only move
+// counts; DistinctPatternCount is always exact.
 func (c *Classifier) Stats() Stats {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	stats := Stats{
-		LearnedCount: c.learnedCount,
+		LearnedCount: int(c.learnedCount.Load()),
+		Timeouts:     c.timeouts.Load(),
 	}
 
-	c.traverseForStats(c.root, 0, &stats)
+	for _, root := range c.roots() {
+		c.traverseForStats(root, 0, &stats, nil)
+	}
+	stats.DistinctPatterns = c.cachedDistinctPatterns()
 	return stats
 }
 
+// MetricsSink receives periodic Stats snapshots from ReportMetrics, e.g. to
+// set Prometheus gauges. Every Stats field is a gauge except Timeouts, which
+// only grows.
+type MetricsSink interface {
+	Observe(stats Stats)
+}
+
+// MetricsSinkFunc adapts an ordinary function to a MetricsSink.
+type MetricsSinkFunc func(stats Stats)
+
+func (f MetricsSinkFunc) Observe(stats Stats) {
+	f(stats)
+}
+
+// ReportMetrics passes Stats to sink right away and then every interval
+// until ctx is done, returning ctx.Err(). It blocks, so run it in its own
+// goroutine. Each report walks the trie under the read lock, like Stats.
+func (c *Classifier) ReportMetrics(ctx context.Context, sink MetricsSink, interval time.Duration) error {
+	return reportMetrics(ctx, c.Stats, sink, interval)
+}
+
+// reportMetrics implements ReportMetrics for any source of Stats.
+func reportMetrics(ctx context.Context, stats func() Stats, sink MetricsSink, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid metrics interval %v: must be positive", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sink.Observe(stats())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DepthDistribution counts the nodes at each depth of the trie, roots being
+// depth 0, to show its shape beyond Stats.MaxDepth: pathological fan-out
+// shows up as a spike at one depth. It uses the same walk as Stats but is
+// kept out of the Stats struct so that Stats stays comparable with ==.
+func (c *Classifier) DepthDistribution() map[int]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var stats Stats
+	histogram := make(map[int]int)
+	for _, root := range c.roots() {
+		c.traverseForStats(root, 0, &stats, histogram)
+	}
+	return histogram
+}
+
 // LearnedCount returns the number of URLs that have been learned.
 func (c *Classifier) LearnedCount() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.learnedCount
+	return int(c.learnedCount.Load())
 }
 
 // NodeCount returns the total number of nodes in the trie.
 func (c *Classifier) NodeCount() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.countNodes(c.root)
+	return c.countAllNodes()
+}
+
+// CardinalityAt reports how variable the position directly below prefix is:
+// for prefix "/users" it describes the values seen at /users/{here}. It
+// returns the ratio of unique values to traversals and the unique-value
+// count. Prefixes running through collapsed nodes follow the wildcard. It
+// returns an error if prefix was never learned, nothing was learned below
+// it, or a *CollapsedSubtreeError if the values there were pruned, leaving
+// the unique count unknown.
+func (c *Classifier) CardinalityAt(prefix string) (float64, int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	unique, total, err := c.cardinalityCounts(prefix)
+	if err != nil {
+		return 0, 0, err
+	}
+	if total == 0 {
+		return 0, 0, fmt.Errorf("nothing has been learned below prefix %q", prefix)
+	}
+	return float64(unique) / float64(total), unique, nil
+}
+
+// cardinalityCounts returns the unique values and traversals of the
+// position below prefix for CardinalityAt, both zero if nothing was learned
+// there. Caller must hold at least the read lock.
+func (c *Classifier) cardinalityCounts(prefix string) (unique, total int, err error) {
+	node := c.root
+	keys := make([]string, 0, 8)
+	for _, part := range c.splitURL(prefix) {
+		key := part
+		if node.collapsed {
+			key = "*"
+		}
+		child := node.children[key]
+		if child == nil {
+			return 0, 0, fmt.Errorf("prefix %q has not been learned", prefix)
+		}
+		node = child
+		keys = append(keys, key)
+	}
+
+	for _, child := range node.children {
+		if child.pruned {
+			// Cardinality is 1.0 but the unique count is unknown
+			return 0, 0, &CollapsedSubtreeError{Op: "cardinality", Prefix: "/" + strings.Join(keys, "/")}
+		}
+		unique += len(child.values)
+		total += child.totalCount.load()
+	}
+	return unique, total, nil
 }
 
 func (c *Classifier) countNodes(node *Segment) int {
@@ -49,12 +172,17 @@ func (c *Classifier) countNodes(node *Segment) int {
 	return count
 }
 
-func (c *Classifier) traverseForStats(node *Segment, depth int, stats *Stats) {
+// traverseForStats accumulates stats over node's subtree, and the node count
+// per depth into histogram if it isn't nil.
+func (c *Classifier) traverseForStats(node *Segment, depth int, stats *Stats, histogram map[int]int) {
 	if node == nil {
 		return
 	}
 
 	stats.NodeCount++
+	if histogram != nil {
+		histogram[depth]++
+	}
 	if depth > stats.MaxDepth {
 		stats.MaxDepth = depth
 	}
@@ -70,17 +198,98 @@ func (c *Classifier) traverseForStats(node *Segment, depth int, stats *Stats) {
 	// Count unique values in this node
 	stats.UniqueValues += len(node.values)
 
-	// Estimate memory for this node:
-	// - Segment struct overhead: ~96 bytes (added pruned bool, uniqueCount int)
-	// - children map: 8 bytes per entry (pointer)
-	// - values map: ~24 bytes per entry (string key avg 16 bytes + int 8 bytes)
-	// - value string: avg 16 bytes
-	stats.MemoryEstimate += 96
-	stats.MemoryEstimate += int64(len(node.children) * 8)
-	stats.MemoryEstimate += int64(len(node.values) * 24)
-	stats.MemoryEstimate += int64(len(node.value))
+	stats.MemoryEstimate += segmentMemory(node)
 
 	for _, child := range node.children {
-		c.traverseForStats(child, depth+1, stats)
+		c.traverseForStats(child, depth+1, stats, histogram)
+	}
+}
+
+// NodeInfo describes the position directly below a trie node, as reported by
+// TopCardinalityNodes.
+type NodeInfo struct {
+	Path         string  // Prefix leading to the position, e.g. /users; * marks a wildcard
+	Cardinality  float64 // Unique values per traversal at the position; 1.0 if pruned
+	UniqueValues int     // Distinct values seen at the position; a lower bound if Pruned
+	TotalCount   int     // Traversals of the position
+	Pruned       bool    // Per-value data was discarded after confirming high cardinality
+}
+
+// TopCardinalityNodes returns the n most variable positions in the trie,
+// highest cardinality first, to show where MaxValuesPerNode and pruning
+// matter. Positions are described by their prefix like CardinalityAt, so
+// /users stands for /users/{here}. The first segment, below the root, is
+// not included.
+func (c *Classifier) TopCardinalityNodes(n int) []NodeInfo {
+	if n <= 0 {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var nodes []NodeInfo
+	c.walkTrie(func(path string, node *Segment, depth int) bool {
+		if depth == 0 || len(node.children) == 0 {
+			return true
+		}
+		info := NodeInfo{Path: path}
+		for _, child := range node.children {
+			info.TotalCount += child.totalCount.load()
+			info.UniqueValues += max(len(child.values), child.uniqueCount)
+			info.Pruned = info.Pruned || child.pruned
+		}
+		if info.TotalCount == 0 {
+			return true
+		}
+		info.Cardinality = float64(info.UniqueValues) / float64(info.TotalCount)
+		if info.Pruned {
+			info.Cardinality = 1.0
+		}
+		nodes = append(nodes, info)
+		return true
+	})
+	return topNodes(nodes, n)
+}
+
+// topNodes sorts nodes for TopCardinalityNodes and keeps the first n.
+func topNodes(nodes []NodeInfo, n int) []NodeInfo {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Cardinality != nodes[j].Cardinality {
+			return nodes[i].Cardinality > nodes[j].Cardinality
+		}
+		if nodes[i].TotalCount != nodes[j].TotalCount {
+			return nodes[i].TotalCount > nodes[j].TotalCount
+		}
+		return nodes[i].Path < nodes[j].Path
+	})
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	return nodes
+}
+
+// walkTrie visits every node of every trie depth-first, children in key
+// order, with the path leading to it: "/" for the method-less root and
+// "GET /" for a method's root. Returning false skips the node's children.
+// Caller must hold at least the read lock.
+func (c *Classifier) walkTrie(fn func(path string, node *Segment, depth int) bool) {
+	var walk func(prefix string, parts []string, node *Segment)
+	walk = func(prefix string, parts []string, node *Segment) {
+		if !fn(prefix+"/"+strings.Join(parts, "/"), node, len(parts)) {
+			return
+		}
+		keys := make([]string, 0, len(node.children))
+		for key := range node.children {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walk(prefix, append(parts, childLabel(key)), node.children[key])
+		}
+	}
+	walk("", nil, c.root)
+	for _, method := range c.sortedMethods() {
+		walk(method+" ", nil, c.methods[method])
 	}
 }
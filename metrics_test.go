@@ -1,8 +1,12 @@
 package classifier
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestStats(t *testing.T) {
@@ -32,6 +36,103 @@ func TestStats(t *testing.T) {
 	}
 }
 
+func TestReportMetrics(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{"/users/123456", "/users/789012"})
+
+	observed := make(chan Stats, 1)
+	sink := MetricsSinkFunc(func(stats Stats) {
+		select {
+		case observed <- stats:
+		default: // drop while the test isn't reading
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.ReportMetrics(ctx, sink, time.Millisecond) }()
+
+	// The first report is immediate
+	if stats := <-observed; stats != c.Stats() {
+		t.Errorf("first Observe() = %+v, want %+v", stats, c.Stats())
+	}
+
+	c.Learn([]string{"/users/345678"})
+	deadline := time.After(5 * time.Second)
+	for waiting := true; waiting; {
+		select {
+		case stats := <-observed:
+			waiting = stats.LearnedCount != 3
+		case <-deadline:
+			t.Fatal("no report with LearnedCount 3")
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("ReportMetrics() = %v, want context.Canceled", err)
+	}
+
+	if err := c.ReportMetrics(context.Background(), sink, 0); err == nil {
+		t.Error("ReportMetrics() with zero interval expected error")
+	}
+}
+
+func TestDepthDistribution(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{
+		"/api/v1/users/123",
+		"/api/v1/users/456",
+		"/api/v1/products/789",
+	})
+
+	histogram := c.DepthDistribution()
+	expected := map[int]int{0: 1, 1: 1, 2: 1, 3: 2, 4: 3}
+	if fmt.Sprint(histogram) != fmt.Sprint(expected) {
+		t.Errorf("DepthDistribution() = %v, want %v", histogram, expected)
+	}
+
+	total := 0
+	for _, count := range histogram {
+		total += count
+	}
+	if nodes := c.Stats().NodeCount; total != nodes {
+		t.Errorf("DepthDistribution() sums to %d, want NodeCount %d", total, nodes)
+	}
+}
+
+func TestTopCardinalityNodes(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{
+		"/users/1/profile",
+		"/users/2/profile",
+		"/users/3/profile",
+		"/users/4/settings",
+		"/docs/intro",
+		"/docs/intro",
+	})
+
+	nodes := c.TopCardinalityNodes(2)
+	if len(nodes) != 2 {
+		t.Fatalf("TopCardinalityNodes(2) returned %d nodes, want 2", len(nodes))
+	}
+	want := NodeInfo{Path: "/users", Cardinality: 1.0, UniqueValues: 4, TotalCount: 4}
+	if nodes[0] != want {
+		t.Errorf("TopCardinalityNodes(2)[0] = %+v, want %+v", nodes[0], want)
+	}
+	for _, node := range nodes {
+		if node.Path == "/" {
+			t.Errorf("TopCardinalityNodes(2) included the root")
+		}
+	}
+	if nodes[1].Cardinality > nodes[0].Cardinality {
+		t.Errorf("TopCardinalityNodes(2) not sorted: %+v", nodes)
+	}
+
+	if got := c.TopCardinalityNodes(0); got != nil {
+		t.Errorf("TopCardinalityNodes(0) = %v, want nil", got)
+	}
+}
+
 func TestLearnedCount(t *testing.T) {
 	c := NewClassifier()
 
@@ -135,6 +236,83 @@ func TestPruneHighCardinality(t *testing.T) {
 	}
 }
 
+func TestCollapseThreshold(t *testing.T) {
+	learn := func(c *Classifier, from, to int) {
+		for i := from; i < to; i++ {
+			c.Learn([]string{fmt.Sprintf("/users/%d/profile", 100000+i)})
+		}
+	}
+
+	t.Run("independent of MaxValuesPerNode", func(t *testing.T) {
+		c := NewClassifier(WithMaxValuesPerNode(5), WithCollapseThreshold(20), WithPruneHighCardinality(true))
+		learn(c, 0, 10)
+		if got := c.Stats().CollapsedNodes; got != 0 {
+			t.Errorf("CollapsedNodes after 10 children = %d, want 0", got)
+		}
+
+		learn(c, 10, 25)
+		if got := c.Stats().CollapsedNodes; got != 1 {
+			t.Errorf("CollapsedNodes after 25 children = %d, want 1", got)
+		}
+		// Values stay capped at MaxValuesPerNode
+		wildcard := c.root.children["users"].children["*"]
+		if wildcard == nil {
+			t.Fatal("users has no wildcard child")
+		}
+		if got := len(wildcard.values); got > 5 {
+			t.Errorf("wildcard tracks %d values, want at most 5", got)
+		}
+	})
+
+	t.Run("falls back to MaxValuesPerNode", func(t *testing.T) {
+		c := NewClassifier(WithMaxValuesPerNode(5), WithPruneHighCardinality(true))
+		learn(c, 0, 10)
+		if got := c.Stats().CollapsedNodes; got != 1 {
+			t.Errorf("CollapsedNodes after 10 children = %d, want 1", got)
+		}
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		c := NewClassifier(WithMaxValuesPerNode(5), WithCollapseThreshold(20))
+		learn(c, 0, 10)
+		if got := c.Prune(); got != 0 {
+			t.Errorf("Prune() with 10 children = %d, want 0", got)
+		}
+		learn(c, 10, 20)
+		if got := c.Prune(); got != 1 {
+			t.Errorf("Prune() with 20 children = %d, want 1", got)
+		}
+	})
+}
+
+func TestCollapsePreservesStaticTails(t *testing.T) {
+	c := NewClassifier(WithMaxValuesPerNode(5), WithPruneHighCardinality(true))
+
+	// The users node collapses on the fifth URL, before every tail has been
+	// seen through the wildcard
+	tails := []string{"settings/notifications", "settings/profile", "settings/security/keys"}
+	for i := 0; i < 6; i++ {
+		uuid := fmt.Sprintf("%08x-0000-4000-8000-%012x", i, i)
+		c.Learn([]string{"/api/users/" + uuid + "/" + tails[i%len(tails)]})
+	}
+
+	if c.Stats().CollapsedNodes == 0 {
+		t.Fatal("users node did not collapse")
+	}
+	counts := c.PatternCounts()
+	for _, tail := range tails {
+		if pattern := "/api/users/{uuid}/" + tail; counts[pattern] != 2 {
+			t.Errorf("PatternCounts()[%q] = %d, want 2: %v", pattern, counts[pattern], counts)
+		}
+	}
+	for _, tail := range tails {
+		result, _ := c.ClassifyOnly("/api/users/d381b052-99eb-40f2-9ede-9bce790faae1/" + tail)
+		if expected := "/api/users/{uuid}/" + tail; result != expected {
+			t.Errorf("ClassifyOnly() = %v, want %v", result, expected)
+		}
+	}
+}
+
 func TestMemoryBoundedLongRunning(t *testing.T) {
 	c := NewClassifier(
 		WithMaxValuesPerNode(50),
@@ -164,3 +342,86 @@ func TestMemoryBoundedLongRunning(t *testing.T) {
 	t.Logf("After 1000 URLs: Nodes=%d, UniqueValues=%d, Collapsed=%d, Memory=%d bytes",
 		stats.NodeCount, stats.UniqueValues, stats.CollapsedNodes, stats.MemoryEstimate)
 }
+
+func TestCardinalityAt(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{
+		"/users/123/profile",
+		"/users/456/profile",
+		"/users/123/settings",
+		"/users/789/profile",
+	})
+
+	ratio, unique, err := c.CardinalityAt("/users")
+	if err != nil {
+		t.Fatalf("CardinalityAt() unexpected error: %v", err)
+	}
+	if unique != 3 {
+		t.Errorf("unique = %d, want 3", unique)
+	}
+	if ratio != 0.75 {
+		t.Errorf("ratio = %v, want 0.75", ratio)
+	}
+
+	ratio, unique, err = c.CardinalityAt("/users/123")
+	if err != nil {
+		t.Fatalf("CardinalityAt() unexpected error: %v", err)
+	}
+	if unique != 2 || ratio != 1.0 {
+		t.Errorf("CardinalityAt(/users/123) = %v, %d, want 1, 2", ratio, unique)
+	}
+
+	for _, prefix := range []string{"/orders", "/users/123/profile"} {
+		if _, _, err := c.CardinalityAt(prefix); err == nil {
+			t.Errorf("CardinalityAt(%q) expected error", prefix)
+		}
+	}
+}
+
+func TestCardinalityAtPruned(t *testing.T) {
+	c := NewClassifier(
+		WithMaxValuesPerNode(3),
+		WithPruneHighCardinality(true),
+	)
+	for i := 0; i < 10; i++ {
+		c.Learn([]string{fmt.Sprintf("/sessions/%08x-0000-0000-0000-%012x/events", i, i)})
+	}
+
+	_, _, err := c.CardinalityAt("/sessions")
+	if collapsedErr, ok := err.(*CollapsedSubtreeError); !ok || collapsedErr.Prefix != "/sessions" {
+		t.Errorf("CardinalityAt() error = %v, want *CollapsedSubtreeError for /sessions", err)
+	}
+
+	// Traversal continues through the collapsed wildcard
+	ratio, unique, err := c.CardinalityAt("/sessions/00000001-0000-0000-0000-000000000001")
+	if err != nil {
+		t.Fatalf("CardinalityAt() unexpected error: %v", err)
+	}
+	if unique != 1 || ratio != 0.1 {
+		t.Errorf("CardinalityAt() = %v, %d, want 0.1, 1", ratio, unique)
+	}
+}
+
+func TestMemoryUsageTracksHeap(t *testing.T) {
+	urls := make([]string, 5000)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("/api/v1/users/%d/profile/%d", 100000+i, i%7)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	c := NewClassifier()
+	c.Learn(urls)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	measured := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+
+	estimate := c.MemoryUsage()
+	if ratio := float64(estimate) / float64(measured); ratio < 0.7 || ratio > 1.3 {
+		t.Errorf("MemoryUsage() = %d, measured heap growth %d (ratio %.2f), want within 30%%", estimate, measured, ratio)
+	}
+	if stats := c.Stats(); stats.MemoryEstimate > estimate {
+		t.Errorf("Stats().MemoryEstimate = %d, want at most MemoryUsage() = %d", stats.MemoryEstimate, estimate)
+	}
+}
@@ -0,0 +1,31 @@
+package classifier
+
+import (
+	"context"
+	"net/http"
+)
+
+// patternKey is the context key under which Middleware stores the pattern.
+type patternKey struct{}
+
+// Middleware returns a handler that classifies each request's path with c,
+// stores the pattern in the request context for PatternFromContext, and
+// calls next, so metrics can be labeled by route template. Since Classify
+// learns, traffic trains c as it is served. While c is still learning
+// (see WithMinLearningCount) the raw path is stored instead.
+func Middleware(c *Classifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, err := c.Classify(r.URL.Path)
+		if err != nil || pattern == "" {
+			pattern = r.URL.Path
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), patternKey{}, pattern)))
+	})
+}
+
+// PatternFromContext returns the pattern Middleware stored in ctx, and
+// whether there was one.
+func PatternFromContext(ctx context.Context) (string, bool) {
+	pattern, ok := ctx.Value(patternKey{}).(string)
+	return pattern, ok
+}
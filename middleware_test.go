@@ -0,0 +1,42 @@
+package classifier
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	c := NewClassifier(WithMinLearningCount(3))
+
+	var got string
+	handler := Middleware(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern, ok := PatternFromContext(r.Context())
+		if !ok {
+			t.Error("PatternFromContext() found no pattern")
+		}
+		got = pattern
+	}))
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		// Still learning: the raw path is passed on
+		{"/users/123456/profile", "/users/123456/profile"},
+		{"/users/789012/profile", "/users/789012/profile"},
+		{"/users/345678/profile", "/users/345678/profile"},
+		{"/users/901234/profile?tab=posts", "/users/{id}/profile"},
+	}
+	for _, tt := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got != tt.expected {
+			t.Errorf("pattern for %s = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+
+	if pattern, ok := PatternFromContext(context.Background()); ok || pattern != "" {
+		t.Errorf("PatternFromContext() without middleware = %q, %v, want empty, false", pattern, ok)
+	}
+}
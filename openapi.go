@@ -0,0 +1,154 @@
+package classifier
+
+import "strings"
+
+// OpenAPIPathItem is an OpenAPI 3 path item with one operation per learned
+// HTTP method.
+type OpenAPIPathItem struct {
+	Get     *OpenAPIOperation `json:"get,omitempty"`
+	Put     *OpenAPIOperation `json:"put,omitempty"`
+	Post    *OpenAPIOperation `json:"post,omitempty"`
+	Delete  *OpenAPIOperation `json:"delete,omitempty"`
+	Options *OpenAPIOperation `json:"options,omitempty"`
+	Head    *OpenAPIOperation `json:"head,omitempty"`
+	Patch   *OpenAPIOperation `json:"patch,omitempty"`
+	Trace   *OpenAPIOperation `json:"trace,omitempty"`
+}
+
+// operation returns the slot for method's operation, or nil if OpenAPI has
+// no such method.
+func (item *OpenAPIPathItem) operation(method string) **OpenAPIOperation {
+	switch method {
+	case "GET":
+		return &item.Get
+	case "PUT":
+		return &item.Put
+	case "POST":
+		return &item.Post
+	case "DELETE":
+		return &item.Delete
+	case "OPTIONS":
+		return &item.Options
+	case "HEAD":
+		return &item.Head
+	case "PATCH":
+		return &item.Patch
+	case "TRACE":
+		return &item.Trace
+	default:
+		return nil
+	}
+}
+
+// openAPIMethods lists the methods with an OpenAPIPathItem slot.
+var openAPIMethods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+// merge fills item's empty operation slots from other.
+func (item *OpenAPIPathItem) merge(other OpenAPIPathItem) {
+	for _, method := range openAPIMethods {
+		if slot := item.operation(method); *slot == nil {
+			*slot = *other.operation(method)
+		}
+	}
+}
+
+// OpenAPIOperation is an OpenAPI 3 operation declaring its path parameters.
+type OpenAPIOperation struct {
+	Parameters []OpenAPIParameter         `json:"parameters,omitempty"`
+	Responses  map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is an OpenAPI 3 path parameter.
+type OpenAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required"`
+	Schema   OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the schema of a path parameter.
+type OpenAPISchema struct {
+	Type    string `json:"type"`
+	Format  string `json:"format,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// OpenAPIResponse is an OpenAPI 3 response; operations require at least one.
+type OpenAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// openAPISchemas maps parameter types to schemas. Types not listed are
+// plain strings. {id} is a string too, since it also covers prefixed IDs
+// like cus_abc123, zero-padded ones and numbers too large for an integer;
+// openAPIPath adds the pattern its values match.
+var openAPISchemas = map[string]OpenAPISchema{
+	"uuid":      {Type: "string", Format: "uuid"},
+	"id":        {Type: "string"},
+	"timestamp": {Type: "integer", Format: "int64"},
+	"float":     {Type: "number"},
+	"date":      {Type: "string", Format: "date"},
+	"email":     {Type: "string", Format: "email"},
+	"ipv4":      {Type: "string", Format: "ipv4"},
+	"ipv6":      {Type: "string", Format: "ipv6"},
+}
+
+// ToOpenAPIPaths converts every learned pattern into an OpenAPI 3 path item,
+// keyed by path template, ready to marshal as the paths object of a
+// document. Parameters are named after their type (as renamed by
+// ParameterNames), with a numeric suffix when a type repeats in one path:
+// /users/{id}/posts/{id2}. Wildcard tails become a {path} parameter.
+// Routes learned with LearnMethod get an operation for their method; routes
+// learned without one are GET. Methods OpenAPI doesn't define are skipped.
+func (c *Classifier) ToOpenAPIPaths() map[string]OpenAPIPathItem {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	paths := make(map[string]OpenAPIPathItem)
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		_, normalized := c.routePattern(method, parts)
+		template, params := c.openAPIPath(normalized)
+		if method == "" {
+			method = "GET"
+		}
+
+		item := paths[template]
+		slot := item.operation(method)
+		if slot == nil || *slot != nil {
+			return
+		}
+		*slot = &OpenAPIOperation{
+			Parameters: params,
+			Responses:  map[string]OpenAPIResponse{"default": {Description: "Observed response"}},
+		}
+		paths[template] = item
+	})
+	return paths
+}
+
+// openAPIPath renders segments as an OpenAPI path template and declares
+// its parameters.
+func (c *Classifier) openAPIPath(segments []normalizedSegment) (string, []OpenAPIParameter) {
+	var params []OpenAPIParameter
+	names := c.routeParamNames(segments)
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		if !seg.param {
+			parts[i] = seg.value
+			continue
+		}
+
+		name := names[i]
+
+		schema, ok := openAPISchemas[seg.value]
+		if !ok {
+			schema = OpenAPISchema{Type: "string"}
+		}
+		if seg.value == "id" {
+			schema.Pattern = "^(?:" + c.paramExpression("id") + ")$"
+		}
+		params = append(params, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: schema})
+		parts[i] = seg.prefix + "{" + name + "}" + seg.suffix
+	}
+	return "/" + strings.Join(parts, "/"), params
+}
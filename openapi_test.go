@@ -0,0 +1,97 @@
+package classifier
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestToOpenAPIPaths(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{
+		"/users/123456/posts/789012",
+		"/users/234567/posts/890123",
+		"/users/345678/posts/901234",
+		"/orders/d381b052-99eb-40f2-9ede-9bce790faae1",
+		"/orders/a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d",
+		"/orders/b2c3d4e5-f6a7-4b8c-9d0e-1f2a3b4c5d6e",
+		"/health",
+	})
+
+	paths := c.ToOpenAPIPaths()
+	idSchema := OpenAPISchema{Type: "string", Pattern: "^(?:" + c.paramExpression("id") + ")$"}
+
+	tests := []struct {
+		path   string
+		params []OpenAPIParameter
+	}{
+		{"/users/{id}/posts/{id2}", []OpenAPIParameter{
+			{Name: "id", In: "path", Required: true, Schema: idSchema},
+			{Name: "id2", In: "path", Required: true, Schema: idSchema},
+		}},
+		{"/orders/{uuid}", []OpenAPIParameter{
+			{Name: "uuid", In: "path", Required: true, Schema: OpenAPISchema{Type: "string", Format: "uuid"}},
+		}},
+		{"/health", nil},
+	}
+
+	if len(paths) != len(tests) {
+		t.Errorf("ToOpenAPIPaths() returned %d paths, want %d: %v", len(paths), len(tests), paths)
+	}
+	for _, tt := range tests {
+		item, ok := paths[tt.path]
+		if !ok || item.Get == nil {
+			t.Errorf("ToOpenAPIPaths() missing GET %s", tt.path)
+			continue
+		}
+		if !reflect.DeepEqual(item.Get.Parameters, tt.params) {
+			t.Errorf("%s parameters = %+v, want %+v", tt.path, item.Get.Parameters, tt.params)
+		}
+	}
+
+	data, err := json.Marshal(paths)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error: %v", err)
+	}
+	var decoded map[string]map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() unexpected error: %v", err)
+	}
+	if _, ok := decoded["/orders/{uuid}"]["get"]; !ok {
+		t.Errorf("marshaled paths = %s, want a get operation under /orders/{uuid}", data)
+	}
+}
+
+func TestToOpenAPIPathsParameterNames(t *testing.T) {
+	c := NewClassifier(WithParameterNames(map[string]string{"id": "userId"}))
+	c.Learn([]string{"/users/123456", "/users/234567", "/users/345678"})
+
+	paths := c.ToOpenAPIPaths()
+	item, ok := paths["/users/{userId}"]
+	if !ok {
+		t.Fatalf("ToOpenAPIPaths() = %v, want /users/{userId}", paths)
+	}
+	if got := item.Get.Parameters[0]; got.Name != "userId" || got.Schema.Type != "string" {
+		t.Errorf("parameter = %+v, want userId with string schema", got)
+	}
+}
+
+func TestToOpenAPIPathsIDSchema(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{"/users/123456", "/users/234567", "/users/345678"})
+
+	schema := c.ToOpenAPIPaths()["/users/{id}"].Get.Parameters[0].Schema
+	if schema.Type != "string" {
+		t.Errorf("{id} schema type = %q, want string", schema.Type)
+	}
+	re := regexp.MustCompile(schema.Pattern)
+	for _, value := range []string{"123456", "000123", "99999999999999999999999", "cus_abc123"} {
+		if !re.MatchString(value) {
+			t.Errorf("{id} schema pattern %q doesn't match %q", schema.Pattern, value)
+		}
+	}
+	if re.MatchString("about") {
+		t.Errorf("{id} schema pattern %q matches about", schema.Pattern)
+	}
+}
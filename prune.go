@@ -0,0 +1,50 @@
+package classifier
+
+import "sort"
+
+// Prune compacts the trie in one pass, collapsing every node whose children
+// meet the same criteria the incremental collapse on insert uses: at least
+// CollapseThreshold children, high variability and mostly dynamic-looking
+// values. Their children are folded into a wildcard that keeps counts but
+// not per-value data, as with WithPruneHighCardinality. Prune works whether
+// or not that option is set, so a burst can be learned in full and compacted
+// afterwards. It returns the number of nodes collapsed.
+func (c *Classifier) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	collapsed := 0
+	for _, root := range c.roots() {
+		collapsed += c.pruneSubtree(root)
+	}
+	if collapsed > 0 {
+		c.nodes = c.countAllNodes()
+		for _, root := range c.roots() {
+			c.clearMerged(root)
+		}
+	}
+	return collapsed
+}
+
+// pruneSubtree collapses node's children if they qualify, then descends
+// into what remains, so wildcards created here are compacted in turn.
+// Caller must hold the write lock.
+func (c *Classifier) pruneSubtree(node *Segment) int {
+	collapsed := 0
+	if !node.collapsed && len(node.children) > 0 &&
+		len(node.children) >= c.config.collapseThreshold() &&
+		c.hasHighVariability(node) && c.childrenLookDynamic(node) {
+		c.collapseChildren(node)
+		collapsed++
+	}
+
+	keys := make([]string, 0, len(node.children))
+	for key := range node.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		collapsed += c.pruneSubtree(node.children[key])
+	}
+	return collapsed
+}
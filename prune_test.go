@@ -0,0 +1,42 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	c := NewClassifier(WithMaxValuesPerNode(5), WithImmutableClassify(true))
+	for i := range 20 {
+		c.Learn([]string{fmt.Sprintf("/sessions/%08x-0000-4000-8000-%012x/events", i, i)})
+	}
+	c.Learn([]string{"/api/users", "/api/orders", "/api/users", "/api/orders"})
+
+	probe := "/sessions/000000ff-0000-4000-8000-0000000000ff/events"
+	before, _ := c.Classify(probe)
+	nodes := c.NodeCount()
+
+	if got := c.Prune(); got != 1 {
+		t.Errorf("Prune() = %d, want 1", got)
+	}
+	if got := c.NodeCount(); got >= nodes {
+		t.Errorf("NodeCount() after Prune() = %d, want fewer than %d", got, nodes)
+	}
+	if got := c.NodeCount(); got != c.countAllNodes() {
+		t.Errorf("NodeCount() = %d, want %d", got, c.countAllNodes())
+	}
+	if stats := c.Stats(); stats.CollapsedNodes != 1 {
+		t.Errorf("Stats().CollapsedNodes = %d, want 1", stats.CollapsedNodes)
+	}
+
+	if after, _ := c.Classify(probe); after != before {
+		t.Errorf("Classify() after Prune() = %v, want %v", after, before)
+	}
+	if result, _ := c.Classify("/api/users"); result != "/api/users" {
+		t.Errorf("Classify() = %v, want /api/users", result)
+	}
+
+	if got := c.Prune(); got != 0 {
+		t.Errorf("second Prune() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,95 @@
+package classifier
+
+import (
+	"sort"
+	"strings"
+)
+
+// queryParam is a single classified query parameter.
+type queryParam struct {
+	key      string
+	value    normalizedSegment
+	hasValue bool
+}
+
+// splitQuery separates the query string from a URL, dropping any fragment.
+func splitQuery(rawURL string) (path, query string) {
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	path, query, _ = strings.Cut(rawURL, "?")
+	return path, query
+}
+
+// queryPairs splits a raw query string into key/value pairs, skipping empty
+// pairs. hasValue is false for bare keys without "=".
+func queryPairs(query string) (pairs [][2]string, hasValue []bool) {
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		pairs = append(pairs, [2]string{key, value})
+		hasValue = append(hasValue, ok)
+	}
+	return pairs, hasValue
+}
+
+// learnQuery records the values seen for each query key of a URL, counted
+// weight times. Caller must hold the write lock.
+func (c *Classifier) learnQuery(rawURL string, weight int) {
+	_, query := splitQuery(rawURL)
+	pairs, _ := queryPairs(query)
+	for _, pair := range pairs {
+		seg := c.queryKeys[pair[0]]
+		if seg == nil {
+			seg = NewSegment(pair[0])
+			c.queryKeys[pair[0]] = seg
+		}
+		c.trackValue(seg, pair[1], weight)
+	}
+}
+
+// normalizeQuery classifies the query parameters of a URL, sorted by key.
+// Keys are kept literal; a value is parameterized when its key has shown
+// high cardinality or the value itself looks like a parameter.
+// Caller must hold at least the read lock.
+func (c *Classifier) normalizeQuery(rawURL string) []queryParam {
+	_, query := splitQuery(rawURL)
+	pairs, hasValue := queryPairs(query)
+
+	params := make([]queryParam, 0, len(pairs))
+	for i, pair := range pairs {
+		key, value := pair[0], pair[1]
+		param := queryParam{key: key, hasValue: hasValue[i], value: literalSegment(value)}
+
+		if value != "" {
+			seg := c.queryKeys[key]
+			if (seg != nil && c.shouldParameterize(seg)) || c.looksLikeParameter(value) {
+				param.value = paramSegment(c.classifyParameterType(value))
+			}
+		}
+		params = append(params, param)
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].key < params[j].key
+	})
+	return params
+}
+
+// renderQuery formats classified query parameters using the configured
+// OutputFormat, including the leading "?". Returns "" for no parameters.
+func (c *Classifier) renderQuery(params []queryParam) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		if !p.hasValue {
+			parts[i] = p.key
+			continue
+		}
+		parts[i] = p.key + "=" + c.formatSegment(p.value)
+	}
+	return "?" + strings.Join(parts, "&")
+}
@@ -0,0 +1,85 @@
+package classifier
+
+import "testing"
+
+func TestClassifyQuery(t *testing.T) {
+	trainingURLs := []string{
+		"/search?q=shoes&page=2&session=9f2c1a7e4b3d&format=json",
+		"/search?q=boots&page=3&session=1a2b3c4d5e6f&format=json",
+		"/search?q=hats&page=4&session=7e6d5c4b3a2f&format=json",
+		"/search?format=json&q=socks&session=0f1e2d3c4b5a&page=5",
+	}
+
+	tests := []struct {
+		name     string
+		testURL  string
+		expected string
+	}{
+		{
+			name:     "values parameterized per key, keys sorted",
+			testURL:  "/search?session=aa11bb22cc33&page=6&q=scarves&format=json",
+			expected: "/search?format=json&page={slug}&q={slug}&session={slug}",
+		},
+		{
+			name:     "dynamic-looking value on unseen key",
+			testURL:  "/search?ref=123456",
+			expected: "/search?ref={id}",
+		},
+		{
+			name:     "bare key and fragment",
+			testURL:  "/search?debug#results",
+			expected: "/search?debug",
+		},
+		{
+			name:     "no query",
+			testURL:  "/search",
+			expected: "/search",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifier := NewClassifier(WithClassifyQuery(true))
+			classifier.Learn(trainingURLs)
+
+			result, err := classifier.Classify(tt.testURL)
+			if err != nil {
+				t.Fatalf("Classify() unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Classify() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+
+	t.Run("query does not create path nodes", func(t *testing.T) {
+		classifier := NewClassifier(WithClassifyQuery(true))
+		classifier.Learn(trainingURLs)
+
+		// root + search
+		if classifier.NodeCount() != 2 {
+			t.Errorf("NodeCount() = %d, want 2", classifier.NodeCount())
+		}
+	})
+
+	t.Run("output independent of key order", func(t *testing.T) {
+		orders := []string{
+			"/search?q=x&page=9&format=json",
+			"/search?format=json&page=9&q=x",
+			"/search?page=9&format=json&q=x",
+		}
+
+		var first string
+		for i, url := range orders {
+			classifier := NewClassifier(WithClassifyQuery(true))
+			classifier.Learn(trainingURLs)
+
+			result, _ := classifier.Classify(url)
+			if i == 0 {
+				first = result
+			} else if result != first {
+				t.Errorf("Classify(%q) = %v, want %v", url, result, first)
+			}
+		}
+	})
+}
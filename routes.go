@@ -0,0 +1,498 @@
+package classifier
+
+import (
+	"iter"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteEntry describes one learned route shape.
+type RouteEntry struct {
+	Pattern    string    // Normalized pattern, e.g. /users/{id}/profile
+	Count      int       // Learned URLs that resolved to this pattern
+	FirstSeen  time.Time // Earliest time a matching URL was learned
+	LastSeen   time.Time // Latest time a matching URL was learned
+	ParamTypes []string  // Parameter types in path order, e.g. [id]
+}
+
+// RouteTable returns every route shape the classifier has learned, with usage
+// counts and first/last-seen times, sorted by count descending.
+func (c *Classifier) RouteTable() []RouteEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	byPattern := make(map[string]*RouteEntry)
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		pattern, normalized := c.routePattern(method, parts)
+
+		entry, exists := byPattern[pattern]
+		if !exists {
+			entry = &RouteEntry{
+				Pattern:    pattern,
+				FirstSeen:  node.firstSeen,
+				LastSeen:   node.lastSeenTime(),
+				ParamTypes: []string{},
+			}
+			for _, seg := range normalized {
+				if seg.param {
+					entry.ParamTypes = append(entry.ParamTypes, seg.value)
+				}
+			}
+			byPattern[pattern] = entry
+		}
+
+		entry.Count += node.endCount.load()
+		if node.firstSeen.Before(entry.FirstSeen) {
+			entry.FirstSeen = node.firstSeen
+		}
+		if seen := node.lastSeenTime(); seen.After(entry.LastSeen) {
+			entry.LastSeen = seen
+		}
+	})
+
+	table := make([]RouteEntry, 0, len(byPattern))
+	for _, entry := range byPattern {
+		table = append(table, *entry)
+	}
+	sortRouteTable(table)
+	return table
+}
+
+// sortRouteTable orders table by count descending, then by pattern.
+func sortRouteTable(table []RouteEntry) {
+	sort.Slice(table, func(i, j int) bool {
+		if table[i].Count != table[j].Count {
+			return table[i].Count > table[j].Count
+		}
+		return table[i].Pattern < table[j].Pattern
+	})
+}
+
+// PatternLastSeen maps each normalized pattern to the latest time a URL
+// resolving to it was learned, e.g. to Forget routes unseen for a while.
+// Times come from the configured Clock. Patterns learned while time tracking
+// was off (see WithTimeTracking) are omitted.
+func (c *Classifier) PatternLastSeen() map[string]time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	lastSeen := make(map[string]time.Time)
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		seen := node.lastSeenTime()
+		if seen.IsZero() {
+			return
+		}
+		pattern, _ := c.routePattern(method, parts)
+		if seen.After(lastSeen[pattern]) {
+			lastSeen[pattern] = seen
+		}
+	})
+	return lastSeen
+}
+
+// forEachEnd calls fn for every end-marked node with the method of its trie
+// ("" for method-less URLs) and the raw path segments leading to it.
+// Wildcard nodes are represented by their most common value so the path
+// still classifies to the right parameter type. parts is only valid for the
+// duration of the call. Caller must hold at least the read lock.
+func (c *Classifier) forEachEnd(fn func(method string, parts []string, node *Segment)) {
+	c.walkEnds(func(method string, parts []string, node *Segment) bool {
+		fn(method, parts, node)
+		return true
+	})
+}
+
+// walkEnds is forEachEnd in a stable order, children by key and method
+// tries after the method-less one, stopping as soon as yield returns false.
+// It reports whether the walk completed.
+func (c *Classifier) walkEnds(yield func(method string, parts []string, node *Segment) bool) bool {
+	return c.walkEndsWhere(nil, yield)
+}
+
+// walkEndsWhere is walkEnds that only enters the subtrees of nodes for
+// which descend, if non-nil, reports true given the path to the node.
+func (c *Classifier) walkEndsWhere(descend func(method string, parts []string) bool, yield func(method string, parts []string, node *Segment) bool) bool {
+	var walk func(method string, node *Segment, parts []string) bool
+	walk = func(method string, node *Segment, parts []string) bool {
+		if node.isEnd && !yield(method, parts, node) {
+			return false
+		}
+		keys := make([]string, 0, len(node.children))
+		for key := range node.children {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := node.children[key]
+			childParts := append(parts, representativeValue(child))
+			if descend != nil && !descend(method, childParts) {
+				continue
+			}
+			if !walk(method, child, childParts) {
+				return false
+			}
+		}
+		return true
+	}
+	if !walk("", c.root, make([]string, 0, 8)) {
+		return false
+	}
+	for _, method := range c.sortedMethods() {
+		if !walk(method, c.methods[method], make([]string, 0, 8)) {
+			return false
+		}
+	}
+	return true
+}
+
+// routePattern classifies learned path parts against method's trie and
+// renders them as ClassifyMethod would.
+func (c *Classifier) routePattern(method string, parts []string) (string, []normalizedSegment) {
+	normalized, _, _ := c.normalizeFrom(c.methodRoot(method), parts, time.Time{})
+	pattern := c.render(normalized)
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+	return pattern, normalized
+}
+
+// representativeValue returns the segment's literal value, or for wildcard
+// nodes the most frequently seen value it absorbed.
+func representativeValue(s *Segment) string {
+	if s.value != "*" || len(s.values) == 0 {
+		return s.value
+	}
+	best, bestCount := "", -1
+	for v, c := range s.values {
+		if cnt := c.load(); cnt > bestCount || (cnt == bestCount && v < best) {
+			best, bestCount = v, cnt
+		}
+	}
+	return best
+}
+
+// Patterns returns every distinct normalized route the classifier currently
+// recognizes, sorted. Patterns use the same parameterization as Classify, so
+// a learned URL classifies to one of the returned patterns.
+func (c *Classifier) Patterns() []string {
+	return slices.Sorted(c.PatternSeq())
+}
+
+// PatternSeq yields the same patterns as Patterns, each once, but lazily
+// from a single walk of the trie instead of collecting them into a slice.
+// They come in trie order, which is stable but not sorted.
+//
+// The read lock is held from the first pattern until the loop ends, so the
+// loop must run to completion or break to release it, and must not call
+// back into the classifier: learning deadlocks immediately, and even reads
+// can deadlock once a writer is waiting for the lock.
+func (c *Classifier) PatternSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		seen := make(map[string]struct{})
+		c.walkEnds(func(method string, parts []string, node *Segment) bool {
+			pattern, _ := c.routePattern(method, parts)
+			if _, ok := seen[pattern]; ok {
+				return true
+			}
+			seen[pattern] = struct{}{}
+			return yield(pattern)
+		})
+	}
+}
+
+// PatternsWithPrefix returns the distinct patterns whose path is prefix or
+// lies below it, sorted, or an empty slice if there are none. prefix is
+// matched segment by segment against the classified trie, so it may cross
+// parameters as patterns render them, e.g. /users/{id}. Only subtrees that
+// classify along prefix are walked. Patterns learned with LearnMethod match
+// by their path, keeping the method.
+func (c *Classifier) PatternsWithPrefix(prefix string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	sep := c.config.separator()
+	prefix = strings.TrimSuffix(prefix, sep)
+	seen := make(map[string]struct{})
+	patterns := []string{}
+	c.walkEndsWhere(c.toward(prefix), func(method string, parts []string, node *Segment) bool {
+		pattern, normalized := c.routePattern(method, parts)
+		if path := c.render(normalized); path != prefix && !strings.HasPrefix(path, prefix+sep) {
+			return true
+		}
+		if _, ok := seen[pattern]; !ok {
+			seen[pattern] = struct{}{}
+			patterns = append(patterns, pattern)
+		}
+		return true
+	})
+	sort.Strings(patterns)
+	return patterns
+}
+
+// toward returns a walkEndsWhere filter that only descends into subtrees
+// whose classified path leads to prefix or lies below it. prefix must not
+// end in the separator.
+func (c *Classifier) toward(prefix string) func(method string, parts []string) bool {
+	sep := c.config.separator()
+	depth := 0
+	if trimmed := strings.TrimPrefix(prefix, sep); trimmed != "" {
+		depth = strings.Count(trimmed, sep) + 1
+	}
+	return func(method string, parts []string) bool {
+		if len(parts) > depth {
+			return true
+		}
+		_, normalized := c.routePattern(method, parts)
+		path := c.render(normalized)
+		return path == prefix || strings.HasPrefix(prefix, path+sep)
+	}
+}
+
+// PatternFor is Classify that also returns how many learned URLs resolved
+// to the pattern's path, as PatternCounts would report it, for a "top
+// routes" view. Both come from the same locked traversal, so the count is
+// consistent with the pattern and, when Classify learns, includes url
+// itself. Only URLs learned without a method are counted.
+func (c *Classifier) PatternFor(url string) (pattern string, hits int, err error) {
+	pattern, _, err = c.learnAndClassifyThen("", url, func(normalized []normalizedSegment) {
+		hits = c.patternHits(c.render(normalized))
+	})
+	if err != nil || pattern == "" {
+		return pattern, 0, err
+	}
+	return pattern, hits, nil
+}
+
+// patternHits returns the learned count of the method-less path, walking
+// only the subtrees that classify along it.
+// Caller must hold at least the read lock.
+func (c *Classifier) patternHits(path string) int {
+	toward := c.toward(path)
+	hits := 0
+	c.walkEndsWhere(func(method string, parts []string) bool {
+		return method == "" && toward(method, parts)
+	}, func(method string, parts []string, node *Segment) bool {
+		if _, normalized := c.routePattern(method, parts); c.render(normalized) == path {
+			hits += node.endCount.load()
+		}
+		return true
+	})
+	return hits
+}
+
+// DistinctPatternCount returns the number of distinct patterns, equal to
+// len(Patterns()) but counted in a single walk without building or sorting
+// the slice. Compared with LearnedCount it shows how much learned traffic
+// repeats.
+func (c *Classifier) DistinctPatternCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.distinctPatterns()
+}
+
+// patternCountKey identifies the state a cached distinct pattern count was
+// taken in: the trie's shape and the settings that can change at runtime.
+type patternCountKey struct {
+	shape       uint64
+	threshold   float64
+	minSamples  int
+	customTypes int
+}
+
+// patternCount is a distinct pattern count cached for Stats.
+type patternCount struct {
+	key patternCountKey
+	n   int
+}
+
+// patternCountKey returns the key of the current state. Caller must hold at
+// least the read lock.
+func (c *Classifier) patternCountKey() patternCountKey {
+	return patternCountKey{
+		shape:       c.shape,
+		threshold:   c.config.CardinalityThreshold,
+		minSamples:  c.config.MinSamples,
+		customTypes: len(c.customTypes),
+	}
+}
+
+// cachedDistinctPatterns is distinctPatterns for Stats, which may run on a
+// timer (ReportMetrics): the count is only taken again once the trie's shape
+// or the runtime settings change, so it can lag changes that only move
+// counts. Caller must hold at least the read lock.
+func (c *Classifier) cachedDistinctPatterns() int {
+	key := c.patternCountKey()
+	if cached := c.patternCount.Load(); cached != nil && cached.key == key {
+		return cached.n
+	}
+	n := c.distinctPatterns()
+	c.patternCount.Store(&patternCount{key: key, n: n})
+	return n
+}
+
+// distinctPatterns implements DistinctPatternCount.
+// Caller must hold at least the read lock.
+func (c *Classifier) distinctPatterns() int {
+	seen := make(map[string]struct{})
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		pattern, _ := c.routePattern(method, parts)
+		seen[pattern] = struct{}{}
+	})
+	return len(seen)
+}
+
+// PatternCounts maps each normalized pattern to the number of learned URLs
+// that resolved to it. Counts retained by collapsed nodes are included, so the
+// totals add up to LearnedCount (minus any empty URLs, which are counted but
+// never inserted).
+func (c *Classifier) PatternCounts() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	counts := make(map[string]int)
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		pattern, _ := c.routePattern(method, parts)
+		counts[pattern] += node.endCount.load()
+	})
+	return counts
+}
+
+// RouteStyle selects the route syntax produced by ToRoutes.
+type RouteStyle int
+
+const (
+	RouteStyleChi        RouteStyle = iota // /users/{id}/profile, catch-all /*
+	RouteStyleHTTPRouter                   // /users/:id/profile, catch-all /*path
+)
+
+// ToRoutes returns every learned pattern as a route string for the given
+// router, deduplicated and ordered so that static segments come before
+// parameters at the same position: /users/me is listed before /users/{id},
+// which matters for routers that match in registration order. Parameters are
+// named as in ToOpenAPIPaths. HTTP methods are dropped, so a route learned
+// for several methods is listed once. httprouter can't express a parameter
+// embedded in static text, so such segments become a single parameter.
+func (c *Classifier) ToRoutes(style RouteStyle) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return sortRoutes(c.routes(style, make(map[string]bool), nil))
+}
+
+// route is a rendered route with the segments it was rendered from, which
+// decide its order.
+type route struct {
+	path     string
+	segments []normalizedSegment
+}
+
+// routes appends the learned routes in style not yet in seen to routes,
+// recording them in seen. Caller must hold at least the read lock.
+func (c *Classifier) routes(style RouteStyle, seen map[string]bool, routes []route) []route {
+	c.forEachEnd(func(method string, parts []string, node *Segment) {
+		_, normalized := c.routePattern(method, parts)
+		path := c.formatRoute(normalized, style)
+		if !seen[path] {
+			seen[path] = true
+			routes = append(routes, route{path, normalized})
+		}
+	})
+	return routes
+}
+
+// sortRoutes orders routes by routeLess and returns their paths.
+func sortRoutes(routes []route) []string {
+	sort.Slice(routes, func(i, j int) bool {
+		return routeLess(routes[i].segments, routes[j].segments, routes[i].path, routes[j].path)
+	})
+
+	result := make([]string, len(routes))
+	for i, r := range routes {
+		result[i] = r.path
+	}
+	return result
+}
+
+// formatRoute renders segments in the given router style.
+func (c *Classifier) formatRoute(segments []normalizedSegment, style RouteStyle) string {
+	names := c.routeParamNames(segments)
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		switch {
+		case !seg.param:
+			parts[i] = seg.value
+		case seg.value == "*" && style == RouteStyleChi:
+			parts[i] = "*"
+		case seg.value == "*":
+			parts[i] = "*" + names[i]
+		case style == RouteStyleHTTPRouter:
+			parts[i] = ":" + names[i]
+		default:
+			parts[i] = seg.prefix + "{" + names[i] + "}" + seg.suffix
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// routeLess orders routes segment by segment: static before parameter
+// before catch-all, static segments alphabetically, and a route before its
+// extensions. Ties fall back to the rendered path.
+func routeLess(a, b []normalizedSegment, pathA, pathB string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		rankA, rankB := routeRank(a[i]), routeRank(b[i])
+		if rankA != rankB {
+			return rankA < rankB
+		}
+		if rankA == 0 && a[i].value != b[i].value {
+			return a[i].value < b[i].value
+		}
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return pathA < pathB
+}
+
+// routeRank is a segment's matching precedence in routers: 0 for static
+// text, 1 for a parameter, 2 for a catch-all.
+func routeRank(seg normalizedSegment) int {
+	switch {
+	case !seg.param:
+		return 0
+	case seg.value == "*":
+		return 2
+	default:
+		return 1
+	}
+}
+
+// routeParamNames names the parameters in segments for route templates: the
+// label as renamed by ParameterNames, numbered when repeated (id, id2), with
+// {*} named path. Entries for literal segments are empty.
+func (c *Classifier) routeParamNames(segments []normalizedSegment) []string {
+	names := make([]string, len(segments))
+	used := make(map[string]int)
+	for i, seg := range segments {
+		if !seg.param {
+			continue
+		}
+		name := seg.value
+		if renamed, ok := c.config.ParameterNames[name]; ok {
+			name = renamed
+		}
+		if seg.value == "*" {
+			name = "path"
+		}
+		used[name]++
+		if n := used[name]; n > 1 {
+			name += strconv.Itoa(n)
+		}
+		names[i] = name
+	}
+	return names
+}
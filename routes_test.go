@@ -0,0 +1,515 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock that advances one minute on every call.
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	c := NewClassifier(WithClock(fakeClock(start)))
+	c.Learn([]string{
+		"/users/123456/profile",
+		"/api/v1/health",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/api/v1/health",
+	})
+
+	table := c.RouteTable()
+	if len(table) != 2 {
+		t.Fatalf("len(RouteTable()) = %d, want 2: %+v", len(table), table)
+	}
+
+	users := table[0]
+	if users.Pattern != "/users/{id}/profile" {
+		t.Errorf("Pattern = %v, want /users/{id}/profile", users.Pattern)
+	}
+	if users.Count != 3 {
+		t.Errorf("Count = %d, want 3", users.Count)
+	}
+	if len(users.ParamTypes) != 1 || users.ParamTypes[0] != "id" {
+		t.Errorf("ParamTypes = %v, want [id]", users.ParamTypes)
+	}
+	if !users.FirstSeen.Equal(start.Add(1 * time.Minute)) {
+		t.Errorf("FirstSeen = %v, want %v", users.FirstSeen, start.Add(1*time.Minute))
+	}
+	if !users.LastSeen.Equal(start.Add(4 * time.Minute)) {
+		t.Errorf("LastSeen = %v, want %v", users.LastSeen, start.Add(4*time.Minute))
+	}
+
+	health := table[1]
+	if health.Pattern != "/api/v1/health" || health.Count != 2 {
+		t.Errorf("table[1] = %+v, want /api/v1/health with count 2", health)
+	}
+	if len(health.ParamTypes) != 0 {
+		t.Errorf("ParamTypes = %v, want none", health.ParamTypes)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	c := NewClassifier()
+	urls := []string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/api/v1/health",
+		"/api/v1/health",
+		"/",
+	}
+	c.Learn(urls)
+
+	expected := []string{"/", "/api/v1/health", "/users/{id}/profile"}
+	patterns := c.Patterns()
+	if len(patterns) != len(expected) {
+		t.Fatalf("Patterns() = %v, want %v", patterns, expected)
+	}
+	for i := range expected {
+		if patterns[i] != expected[i] {
+			t.Errorf("Patterns()[%d] = %v, want %v", i, patterns[i], expected[i])
+		}
+	}
+
+	// Every learned URL classifies to a listed pattern
+	listed := make(map[string]bool)
+	for _, p := range patterns {
+		listed[p] = true
+	}
+	for _, url := range urls {
+		result := c.render(c.normalize(c.splitURL(url)))
+		if !listed[result] {
+			t.Errorf("Classify(%q) = %v, not in Patterns()", url, result)
+		}
+	}
+}
+
+// collapsedUUIDClassifier returns a classifier that has collapsed the
+// children of /users, all UUIDs, into a wildcard.
+func collapsedUUIDClassifier(t *testing.T) *Classifier {
+	t.Helper()
+	c := NewClassifier(WithMaxValuesPerNode(5), WithPruneHighCardinality(true))
+	c.Learn([]string{
+		"/users/d381b052-99eb-40f2-9ede-9bce790faae1/profile",
+		"/users/5f0c6a8e-1b2d-4c3e-8f9a-0b1c2d3e4f5a/profile",
+		"/users/9a8b7c6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d/profile",
+		"/users/0e1d2c3b-4a59-4687-b5a4-c3d2e1f0a9b8/profile",
+		"/users/7c6b5a49-3827-4165-a4b3-c2d1e0f9a8b7/profile",
+	})
+	if c.Stats().CollapsedNodes == 0 {
+		t.Fatal("expected /users to collapse")
+	}
+	return c
+}
+
+func TestPatternsAfterCollapse(t *testing.T) {
+	c := collapsedUUIDClassifier(t)
+	want, err := c.ClassifyOnly("/users/d381b052-99eb-40f2-9ede-9bce790faae1/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != "/users/{uuid}/profile" {
+		t.Fatalf("ClassifyOnly = %v, want /users/{uuid}/profile", want)
+	}
+	if patterns := c.Patterns(); len(patterns) != 1 || patterns[0] != want {
+		t.Errorf("Patterns() = %v, want [%v]", patterns, want)
+	}
+}
+
+func TestExportsAfterCollapse(t *testing.T) {
+	c := collapsedUUIDClassifier(t)
+
+	if table := c.RouteTable(); len(table) != 1 || table[0].Pattern != "/users/{uuid}/profile" || fmt.Sprint(table[0].ParamTypes) != "[uuid]" {
+		t.Errorf("RouteTable() = %+v, want /users/{uuid}/profile with param types [uuid]", table)
+	}
+	if routes := c.ToRoutes(RouteStyleChi); fmt.Sprint(routes) != "[/users/{uuid}/profile]" {
+		t.Errorf("ToRoutes(RouteStyleChi) = %v, want [/users/{uuid}/profile]", routes)
+	}
+	if paths := c.ToOpenAPIPaths(); len(paths) != 1 {
+		t.Errorf("ToOpenAPIPaths() = %+v, want only /users/{uuid}/profile", paths)
+	} else if _, ok := paths["/users/{uuid}/profile"]; !ok {
+		t.Errorf("ToOpenAPIPaths() = %+v, want /users/{uuid}/profile", paths)
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportStats(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var stats PatternStats
+	if err := json.Unmarshal(buf.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pattern != "/users/{uuid}/profile" || stats.Count != 5 {
+		t.Errorf("ExportStats() = %+v, want /users/{uuid}/profile with count 5", stats)
+	}
+}
+
+func TestPatternSeq(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/api/v1/health",
+		"/api/v1/health",
+	})
+	c.LearnMethod("GET", []string{"/docs"})
+
+	var patterns []string
+	for pattern := range c.PatternSeq() {
+		patterns = append(patterns, pattern)
+	}
+	expected := []string{"/api/v1/health", "/users/{id}/profile", "GET /docs"}
+	if fmt.Sprint(patterns) != fmt.Sprint(expected) {
+		t.Errorf("PatternSeq() = %v, want %v", patterns, expected)
+	}
+
+	// Breaking out early releases the read lock
+	for range c.PatternSeq() {
+		break
+	}
+	c.Learn([]string{"/orders"})
+	if got := len(c.Patterns()); got != 4 {
+		t.Errorf("len(Patterns()) = %d after learning, want 4", got)
+	}
+}
+
+func TestDistinctPatternCount(t *testing.T) {
+	urls := []string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/users/345678/profile",
+		"/users/901234/settings",
+		"/api/v1/health",
+		"/api/v1/health",
+		"/api/v1/health",
+	}
+	c := NewClassifier()
+	c.Learn(urls)
+	c.LearnMethod("GET", []string{"/api/v1/health"})
+
+	// 9 URLs over /users/{id}/profile, /users/{id}/settings, /api/v1/health
+	// and GET /api/v1/health
+	if got := c.DistinctPatternCount(); got != 4 {
+		t.Errorf("DistinctPatternCount() = %d, want 4", got)
+	}
+	if got, want := c.DistinctPatternCount(), len(c.Patterns()); got != want {
+		t.Errorf("DistinctPatternCount() = %d, want len(Patterns()) = %d", got, want)
+	}
+	if stats := c.Stats(); stats.DistinctPatterns != 4 || stats.LearnedCount != 9 {
+		t.Errorf("Stats() DistinctPatterns = %d, LearnedCount = %d, want 4 and 9", stats.DistinctPatterns, stats.LearnedCount)
+	}
+
+	if got := NewClassifier().DistinctPatternCount(); got != 0 {
+		t.Errorf("DistinctPatternCount() on empty classifier = %d, want 0", got)
+	}
+
+	s := NewShardedClassifier(4)
+	s.Learn(urls)
+	if got, want := s.DistinctPatternCount(), len(s.Patterns()); got != want || got != 3 {
+		t.Errorf("sharded DistinctPatternCount() = %d, want len(Patterns()) = %d and 3", got, want)
+	}
+	if got := s.Stats().DistinctPatterns; got != 3 {
+		t.Errorf("sharded Stats().DistinctPatterns = %d, want 3", got)
+	}
+}
+
+func TestStatsDistinctPatternsCache(t *testing.T) {
+	urls := []string{"/users/123456/profile", "/users/789012/profile", "/users/345678/profile", "/api/v1/health"}
+	c := NewClassifier()
+	c.Learn(urls)
+	if got := c.Stats().DistinctPatterns; got != 2 {
+		t.Fatalf("Stats().DistinctPatterns = %d, want 2", got)
+	}
+
+	// Learning known routes again leaves the trie's shape, and the cache, alone
+	cached := c.patternCount.Load()
+	c.Learn([]string{"/api/v1/health", "/users/123456/profile"})
+	if got := c.Stats().DistinctPatterns; got != 2 || c.patternCount.Load() != cached {
+		t.Errorf("Stats().DistinctPatterns = %d and recounted, want the cached 2", got)
+	}
+
+	c.Learn([]string{"/orders"})
+	if got := c.Stats().DistinctPatterns; got != 3 {
+		t.Errorf("Stats().DistinctPatterns after a new route = %d, want 3", got)
+	}
+	cached = c.patternCount.Load()
+	if err := c.SetMinSamples(10); err != nil {
+		t.Fatal(err)
+	}
+	c.Stats()
+	if c.patternCount.Load() == cached {
+		t.Error("Stats() kept the count cached before SetMinSamples")
+	}
+	c.Reset()
+	if got := c.Stats().DistinctPatterns; got != 0 {
+		t.Errorf("Stats().DistinctPatterns after Reset = %d, want 0", got)
+	}
+
+	s := NewShardedClassifier(4)
+	s.Learn(urls)
+	if got := s.Stats().DistinctPatterns; got != 2 {
+		t.Errorf("sharded Stats().DistinctPatterns = %d, want 2", got)
+	}
+	s.Learn([]string{"/orders"})
+	if got := s.Stats().DistinctPatterns; got != 3 {
+		t.Errorf("sharded Stats().DistinctPatterns after a new route = %d, want 3", got)
+	}
+}
+
+func TestPatternsWithPrefix(t *testing.T) {
+	c := NewClassifier()
+	c.Learn([]string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/settings",
+		"/usersettings",
+		"/api/v1/health",
+		"/api/v2/users",
+		"/api/v2/orders",
+	})
+	c.LearnMethod("GET", []string{"/api/v2/docs"})
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"/api/v2", []string{"/api/v2/orders", "/api/v2/users", "GET /api/v2/docs"}},
+		{"/api/v2/", []string{"/api/v2/orders", "/api/v2/users", "GET /api/v2/docs"}},
+		{"/api/v2/users", []string{"/api/v2/users"}},
+		{"/users/{id}", []string{"/users/{id}/profile", "/users/{id}/settings"}},
+		{"/users", []string{"/users/{id}/profile", "/users/{id}/settings"}},
+		{"/users/123456", []string{}},
+		{"/missing", []string{}},
+		{"", c.Patterns()},
+	}
+	for _, tt := range tests {
+		got := c.PatternsWithPrefix(tt.prefix)
+		if got == nil || fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("PatternsWithPrefix(%q) = %#v, want %v", tt.prefix, got, tt.expected)
+		}
+	}
+}
+
+func TestPatternCounts(t *testing.T) {
+	t.Run("counts per pattern", func(t *testing.T) {
+		c := NewClassifier()
+		c.Learn([]string{
+			"/users/123456/profile",
+			"/users/789012/profile",
+			"/users/345678/profile",
+			"/users/345678",
+			"/api/v1/health",
+		})
+
+		counts := c.PatternCounts()
+		if counts["/users/{id}/profile"] != 3 {
+			t.Errorf("counts[/users/{id}/profile] = %d, want 3", counts["/users/{id}/profile"])
+		}
+		if counts["/api/v1/health"] != 1 {
+			t.Errorf("counts[/api/v1/health] = %d, want 1", counts["/api/v1/health"])
+		}
+	})
+
+	t.Run("totals add up with collapsed nodes", func(t *testing.T) {
+		c := NewClassifier(WithMaxValuesPerNode(10), WithPruneHighCardinality(true))
+		for i := 0; i < 200; i++ {
+			c.Learn([]string{
+				fmt.Sprintf("/api/users/%08x-0000-4000-8000-%012x/profile", i, i),
+				"/api/health",
+			})
+		}
+		if c.Stats().CollapsedNodes == 0 {
+			t.Fatal("expected collapsed nodes")
+		}
+
+		total := 0
+		for _, n := range c.PatternCounts() {
+			total += n
+		}
+		if total != c.LearnedCount() {
+			t.Errorf("sum(PatternCounts()) = %d, want %d", total, c.LearnedCount())
+		}
+	})
+}
+
+func TestPatternFor(t *testing.T) {
+	training := []string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+		"/users/345678",
+		"/api/v1/health",
+	}
+
+	t.Run("counts the classified URL", func(t *testing.T) {
+		c := NewClassifier()
+		c.Learn(training)
+		c.LearnMethod("GET", []string{"/api/v1/health"})
+
+		tests := []struct {
+			url     string
+			pattern string
+			hits    int
+		}{
+			{"/users/901234/profile", "/users/{id}/profile", 4},
+			{"/users/567890/profile", "/users/{id}/profile", 5},
+			{"/api/v1/health", "/api/v1/health", 2},
+			{"/orders", "/orders", 1},
+		}
+		for _, tt := range tests {
+			pattern, hits, err := c.PatternFor(tt.url)
+			if err != nil {
+				t.Fatalf("PatternFor(%q) unexpected error: %v", tt.url, err)
+			}
+			if pattern != tt.pattern || hits != tt.hits {
+				t.Errorf("PatternFor(%q) = %q, %d, want %q, %d", tt.url, pattern, hits, tt.pattern, tt.hits)
+			}
+			if counts := c.PatternCounts(); counts[pattern] != hits {
+				t.Errorf("PatternCounts()[%q] = %d, want %d", pattern, counts[pattern], hits)
+			}
+		}
+	})
+
+	t.Run("without learning", func(t *testing.T) {
+		c := NewClassifier(WithImmutableClassify(true))
+		c.Learn(training)
+
+		pattern, hits, err := c.PatternFor("/users/901234/profile")
+		if err != nil || pattern != "/users/{id}/profile" || hits != 3 {
+			t.Errorf("PatternFor() = %q, %d, %v, want /users/{id}/profile, 3, nil", pattern, hits, err)
+		}
+		if _, hits, _ := c.PatternFor("/unknown"); hits != 0 {
+			t.Errorf("PatternFor(/unknown) hits = %d, want 0", hits)
+		}
+	})
+
+	t.Run("still learning", func(t *testing.T) {
+		c := NewClassifier(WithMinLearningCount(10))
+		pattern, hits, err := c.PatternFor("/users/123456")
+		if _, ok := err.(*InsufficientDataError); !ok || pattern != "" || hits != 0 {
+			t.Errorf("PatternFor() = %q, %d, %v, want InsufficientDataError", pattern, hits, err)
+		}
+	})
+}
+
+func TestToRoutes(t *testing.T) {
+	// Routes learned for different methods overlap once methods are dropped
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{
+		"/about",
+		"/orgs/123456/members/234567",
+		"/orgs/234567/members/345678",
+		"/orgs/345678/members/456789",
+	})
+	c.LearnMethod("GET", []string{"/users/me", "/users/me/settings"})
+	c.LearnMethod("DELETE", []string{
+		"/users/123456",
+		"/users/234567",
+		"/users/345678",
+		"/users/456789/profile",
+		"/users/567890/profile",
+		"/users/678901/profile",
+	})
+
+	tests := []struct {
+		style    RouteStyle
+		expected []string
+	}{
+		{RouteStyleChi, []string{
+			"/about",
+			"/orgs/{id}/members/{id2}",
+			"/users/me",
+			"/users/me/settings",
+			"/users/{id}",
+			"/users/{id}/profile",
+		}},
+		{RouteStyleHTTPRouter, []string{
+			"/about",
+			"/orgs/:id/members/:id2",
+			"/users/me",
+			"/users/me/settings",
+			"/users/:id",
+			"/users/:id/profile",
+		}},
+	}
+
+	for _, tt := range tests {
+		routes := c.ToRoutes(tt.style)
+		if fmt.Sprint(routes) != fmt.Sprint(tt.expected) {
+			t.Errorf("ToRoutes(%d) = %v, want %v", tt.style, routes, tt.expected)
+		}
+	}
+}
+
+func TestToRoutesCatchAll(t *testing.T) {
+	c := NewClassifier(WithWildcardTail(true), WithImmutableClassify(true))
+	c.Learn([]string{
+		"/files/a/b/c.txt",
+		"/files/d.txt",
+		"/files/e/f.txt",
+		"/files/readme",
+		"/files/readme",
+	})
+
+	if routes := c.ToRoutes(RouteStyleChi); fmt.Sprint(routes) != "[/files/*]" {
+		t.Errorf("ToRoutes(RouteStyleChi) = %v, want [/files/*]", routes)
+	}
+	if routes := c.ToRoutes(RouteStyleHTTPRouter); fmt.Sprint(routes) != "[/files/*path]" {
+		t.Errorf("ToRoutes(RouteStyleHTTPRouter) = %v, want [/files/*path]", routes)
+	}
+}
+
+func TestPatternLastSeen(t *testing.T) {
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	c := NewClassifier(WithClock(fakeClock(start)))
+	c.Learn([]string{
+		"/users/123456",
+		"/api/v1/health",
+		"/users/789012",
+		"/users/345678",
+	})
+
+	expected := map[string]time.Time{
+		"/users/{id}":    start.Add(4 * time.Minute),
+		"/api/v1/health": start.Add(2 * time.Minute),
+	}
+	lastSeen := c.PatternLastSeen()
+	if len(lastSeen) != len(expected) {
+		t.Errorf("PatternLastSeen() = %v, want %v", lastSeen, expected)
+	}
+	for pattern, want := range expected {
+		if got := lastSeen[pattern]; !got.Equal(want) {
+			t.Errorf("PatternLastSeen()[%q] = %v, want %v", pattern, got, want)
+		}
+	}
+}
+
+func TestWithTimeTrackingDisabled(t *testing.T) {
+	calls := 0
+	clock := func() time.Time {
+		calls++
+		return time.Now()
+	}
+	c := NewClassifier(WithClock(clock), WithTimeTracking(false))
+	c.Learn([]string{"/users/123456", "/users/789012"})
+
+	if calls != 0 {
+		t.Errorf("Clock called %d times, want 0", calls)
+	}
+	if lastSeen := c.PatternLastSeen(); len(lastSeen) != 0 {
+		t.Errorf("PatternLastSeen() = %v, want empty", lastSeen)
+	}
+}
@@ -1,35 +1,101 @@
 package classifier
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 type Segment struct {
 	value       string
 	children    map[string]*Segment
 	isEnd       bool
-	values      map[string]int
-	totalCount  int
-	pruned      bool // true if values map was cleared after confirming high cardinality
-	uniqueCount int  // preserved count of unique values when pruned
-	collapsed   bool // true if children were collapsed into wildcard (memory optimization)
+	values      map[string]*counter
+	totalCount  counter
+	pruned      bool    // true if values map was cleared after confirming high cardinality
+	uniqueCount int     // preserved count of unique values when pruned
+	collapsed   bool    // true if children were collapsed into wildcard (memory optimization)
+	endCount    counter // number of learned URLs that ended at this node
+	slashEnd    bool    // a learned URL ended here with a trailing slash (TrailingSlashRedirect)
+	lastAccess  uint64  // insert tick of the last learned URL through this node (MaxNodes)
+	firstSeen   time.Time
+	lastSeen    atomic.Int64            // UnixNano of lastSeenTime, 0 if never; see seen
+	samples     []string                // ring of recent raw values (SampleRetention)
+	sampleNext  int                     // ring index the next sample overwrites once full
+	merged      atomic.Pointer[Segment] // cached virtual node of merged grandchildren
+	fastLearns  atomic.Uint64           // learnFast calls started (high 32 bits) and in flight (low 32 bits) below this node
+	owner       *Segment                // trie node whose merged view holds this virtual node; nil for trie nodes
+	ends        atomic.Pointer[endSpan] // cached end depths of the subtree; see endDepths
 }
 
 func NewSegment(value string) *Segment {
 	return &Segment{
 		value:    value,
 		children: make(map[string]*Segment),
-		values:   make(map[string]int),
+		values:   make(map[string]*counter),
 	}
 }
 
+// counter is a count that learnFast can bump under the read lock while
+// other readers use it, so every access goes through atomics.
+type counter struct {
+	n atomic.Int64
+}
+
+func (c *counter) load() int       { return int(c.n.Load()) }
+func (c *counter) add(delta int)   { c.n.Add(int64(delta)) }
+func (c *counter) store(value int) { c.n.Store(int64(value)) }
+
+// fastLearnStarted is the fastLearns increment for one started learnFast;
+// the low bits count those still in flight.
+const fastLearnStarted = 1 << 32
+
+// beginFastLearn records that learnFast is about to change counters that
+// merged views cached at s may have copied.
+func (s *Segment) beginFastLearn() { s.fastLearns.Add(fastLearnStarted + 1) }
+
+// endFastLearn records that a learnFast begun with beginFastLearn is done.
+func (s *Segment) endFastLearn() { s.fastLearns.Add(^uint64(0)) }
+
+// viewOwner returns the trie node whose merged view s belongs to, or s
+// itself if it is a trie node.
+func (s *Segment) viewOwner() *Segment {
+	if s.owner != nil {
+		return s.owner
+	}
+	return s
+}
+
+// valueCount returns how often value was seen at s.
+func (s *Segment) valueCount(value string) int {
+	if cnt := s.values[value]; cnt != nil {
+		return cnt.load()
+	}
+	return 0
+}
+
+// addValue adds delta to the count of value, tracking it if it is new.
+// Tracking a new value needs the write lock.
+func (s *Segment) addValue(value string, delta int) {
+	cnt := s.values[value]
+	if cnt == nil {
+		cnt = new(counter)
+		s.values[value] = cnt
+	}
+	cnt.add(delta)
+}
+
 // Cardinality returns the ratio of unique values to total occurrences.
 // For pruned nodes, returns 1.0 (confirmed high cardinality).
 // For capped nodes, uses the capped unique count.
 func (s *Segment) Cardinality() float64 {
-	if s.totalCount == 0 {
+	total := s.totalCount.load()
+	if total == 0 {
 		return 0
 	}
 	if s.pruned {
 		return 1.0 // confirmed high cardinality
 	}
-	return float64(len(s.values)) / float64(s.totalCount)
+	return float64(len(s.values)) / float64(total)
 }
 
 func (s *Segment) IsHighCardinality(threshold float64) bool {
@@ -41,3 +107,102 @@ func (s *Segment) IsHighCardinality(threshold float64) bool {
 func (s *Segment) IsPruned() bool {
 	return s.pruned
 }
+
+// clone returns a deep copy of the segment and its subtree.
+func (s *Segment) clone() *Segment {
+	cp := &Segment{
+		value:       s.value,
+		children:    make(map[string]*Segment, len(s.children)),
+		isEnd:       s.isEnd,
+		values:      make(map[string]*counter, len(s.values)),
+		pruned:      s.pruned,
+		uniqueCount: s.uniqueCount,
+		collapsed:   s.collapsed,
+		slashEnd:    s.slashEnd,
+		lastAccess:  s.lastAccess,
+		firstSeen:   s.firstSeen,
+		samples:     append([]string(nil), s.samples...),
+		sampleNext:  s.sampleNext,
+	}
+	cp.totalCount.store(s.totalCount.load())
+	cp.lastSeen.Store(s.lastSeen.Load())
+	cp.endCount.store(s.endCount.load())
+	for k, v := range s.values {
+		cp.addValue(k, v.load())
+	}
+	for k, child := range s.children {
+		cp.children[k] = child.clone()
+	}
+	return cp
+}
+
+// markEnd records weight learned URLs ending at this segment at the given
+// time.
+func (s *Segment) markEnd(now time.Time, weight int) {
+	s.isEnd = true
+	s.endCount.add(weight)
+	if s.firstSeen.IsZero() || now.Before(s.firstSeen) {
+		s.firstSeen = now
+	}
+	s.seen(now)
+}
+
+// absorbEnd folds other's terminal stats into s.
+func (s *Segment) absorbEnd(other *Segment) {
+	if !other.isEnd {
+		return
+	}
+	s.isEnd = true
+	s.endCount.add(other.endCount.load())
+	s.slashEnd = s.slashEnd || other.slashEnd
+	if s.firstSeen.IsZero() || (!other.firstSeen.IsZero() && other.firstSeen.Before(s.firstSeen)) {
+		s.firstSeen = other.firstSeen
+	}
+	s.seen(other.lastSeenTime())
+}
+
+// lastSeenTime returns when a learned URL last ended at s, or the zero time.
+func (s *Segment) lastSeenTime() time.Time {
+	if n := s.lastSeen.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// seen moves the last-seen time of s forward to now. It is atomic so that
+// learnFast can record it under the read lock.
+func (s *Segment) seen(now time.Time) {
+	if now.IsZero() {
+		return
+	}
+	n := now.UnixNano()
+	for {
+		last := s.lastSeen.Load()
+		if last >= n || s.lastSeen.CompareAndSwap(last, n) {
+			return
+		}
+	}
+}
+
+// addSample records value in the ring of at most n recent samples,
+// overwriting the oldest once it is full.
+func (s *Segment) addSample(value string, n int) {
+	if n <= 0 {
+		return
+	}
+	if len(s.samples) < n {
+		s.samples = append(s.samples, value)
+		return
+	}
+	s.samples[s.sampleNext%len(s.samples)] = value
+	s.sampleNext = (s.sampleNext + 1) % len(s.samples)
+}
+
+// recentSamples returns a copy of the retained samples, oldest first.
+func (s *Segment) recentSamples() []string {
+	if len(s.samples) == 0 {
+		return nil
+	}
+	next := s.sampleNext % len(s.samples)
+	return append(append([]string(nil), s.samples[next:]...), s.samples[:next]...)
+}
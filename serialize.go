@@ -0,0 +1,251 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// snapshotVersion is the current serialization format version. Bump it when
+// the snapshot layout changes and keep decoding older versions.
+const snapshotVersion = 1
+
+// classifierSnapshot is the serializable form of a Classifier.
+type classifierSnapshot struct {
+	Version      int                         `json:"version"`
+	Config       *Config                     `json:"config"`
+	LearnedCount int                         `json:"learned_count"`
+	Root         *segmentSnapshot            `json:"root"`
+	QueryKeys    map[string]*segmentSnapshot `json:"query_keys,omitempty"`
+	Methods      map[string]*segmentSnapshot `json:"methods,omitempty"`
+	Hosts        *segmentSnapshot            `json:"hosts,omitempty"`
+	Tick         uint64                      `json:"tick,omitempty"`
+}
+
+// segmentSnapshot mirrors Segment with exported fields.
+type segmentSnapshot struct {
+	Value       string                      `json:"value"`
+	Children    map[string]*segmentSnapshot `json:"children,omitempty"`
+	IsEnd       bool                        `json:"is_end,omitempty"`
+	Values      map[string]int              `json:"values,omitempty"`
+	TotalCount  int                         `json:"total_count"`
+	Pruned      bool                        `json:"pruned,omitempty"`
+	UniqueCount int                         `json:"unique_count,omitempty"`
+	Collapsed   bool                        `json:"collapsed,omitempty"`
+	EndCount    int                         `json:"end_count,omitempty"`
+	SlashEnd    bool                        `json:"slash_end,omitempty"`
+	FirstSeen   time.Time                   `json:"first_seen,omitzero"`
+	LastSeen    time.Time                   `json:"last_seen,omitzero"`
+	Samples     []string                    `json:"samples,omitempty"`
+	LastAccess  uint64                      `json:"last_access,omitempty"`
+}
+
+// newSegmentSnapshot captures s and its subtree, including the insert tick
+// of each node's last access if access is set.
+func newSegmentSnapshot(s *Segment, access bool) *segmentSnapshot {
+	snap := &segmentSnapshot{
+		Value:       s.value,
+		IsEnd:       s.isEnd,
+		TotalCount:  s.totalCount.load(),
+		Pruned:      s.pruned,
+		UniqueCount: s.uniqueCount,
+		Collapsed:   s.collapsed,
+		EndCount:    s.endCount.load(),
+		SlashEnd:    s.slashEnd,
+		FirstSeen:   s.firstSeen,
+		LastSeen:    s.lastSeenTime(),
+		Samples:     s.recentSamples(),
+	}
+	if access {
+		snap.LastAccess = s.lastAccess
+	}
+	if len(s.values) > 0 {
+		snap.Values = make(map[string]int, len(s.values))
+		for v, cnt := range s.values {
+			snap.Values[v] = cnt.load()
+		}
+	}
+	if len(s.children) > 0 {
+		snap.Children = make(map[string]*segmentSnapshot, len(s.children))
+		for k, child := range s.children {
+			snap.Children[k] = newSegmentSnapshot(child, access)
+		}
+	}
+	return snap
+}
+
+func (snap *segmentSnapshot) segment() *Segment {
+	s := NewSegment(snap.Value)
+	s.isEnd = snap.IsEnd
+	s.totalCount.store(snap.TotalCount)
+	s.pruned = snap.Pruned
+	s.uniqueCount = snap.UniqueCount
+	s.collapsed = snap.Collapsed
+	s.endCount.store(snap.EndCount)
+	s.slashEnd = snap.SlashEnd
+	s.firstSeen = snap.FirstSeen
+	s.seen(snap.LastSeen)
+	s.samples = append([]string(nil), snap.Samples...)
+	s.lastAccess = snap.LastAccess
+	for v, cnt := range snap.Values {
+		s.addValue(v, cnt)
+	}
+	for k, child := range snap.Children {
+		s.children[k] = child.segment()
+	}
+	return s
+}
+
+// snapshot captures the classifier state. Caller must hold at least the read lock.
+func (c *Classifier) snapshot() *classifierSnapshot {
+	// Access ticks only order MaxNodes eviction, and learnFast, which is
+	// off under MaxNodes, doesn't maintain them
+	access := c.config.MaxNodes > 0
+	snap := &classifierSnapshot{
+		Version:      snapshotVersion,
+		Config:       c.config.withoutDetectors(),
+		LearnedCount: int(c.learnedCount.Load()),
+		Root:         newSegmentSnapshot(c.root, access),
+	}
+	if access {
+		snap.Tick = c.tick
+	}
+	if len(c.queryKeys) > 0 {
+		snap.QueryKeys = make(map[string]*segmentSnapshot, len(c.queryKeys))
+		for key, seg := range c.queryKeys {
+			snap.QueryKeys[key] = newSegmentSnapshot(seg, access)
+		}
+	}
+	if len(c.methods) > 0 {
+		snap.Methods = make(map[string]*segmentSnapshot, len(c.methods))
+		for method, root := range c.methods {
+			snap.Methods[method] = newSegmentSnapshot(root, access)
+		}
+	}
+	if c.hosts != nil {
+		snap.Hosts = newSegmentSnapshot(c.hosts, access)
+	}
+	return snap
+}
+
+// validate reports whether snap can be restored.
+func (snap *classifierSnapshot) validate() error {
+	if snap.Version < 1 || snap.Version > snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	if snap.Root == nil {
+		return fmt.Errorf("snapshot has no root segment")
+	}
+	return nil
+}
+
+// restore replaces the classifier state with snap, resetting what is derived
+// from classifying, as Reset does. Caller must hold the write lock.
+func (c *Classifier) restore(snap *classifierSnapshot) error {
+	if err := snap.validate(); err != nil {
+		return err
+	}
+
+	if snap.Config == nil {
+		snap.Config = DefaultConfig()
+	}
+	// Detectors and the clock are code, not data: keep the ones this
+	// classifier was built with
+	if c.config != nil {
+		snap.Config.Detectors = c.config.Detectors
+		snap.Config.AdditionalDetectors = c.config.AdditionalDetectors
+		snap.Config.SegmentOverride = c.config.SegmentOverride
+		snap.Config.Clock = c.config.Clock
+	}
+	c.config = snap.Config
+	c.buildDetectors()
+	c.learnedCount.Store(int64(snap.LearnedCount))
+	c.root = snap.Root.segment()
+	c.methods = make(map[string]*Segment, len(snap.Methods))
+	for method, root := range snap.Methods {
+		c.methods[method] = root.segment()
+	}
+	c.hosts = nil
+	if snap.Hosts != nil {
+		c.hosts = snap.Hosts.segment()
+	}
+	c.nodes = c.countAllNodes()
+	c.queryKeys = make(map[string]*Segment, len(snap.QueryKeys))
+	for key, seg := range snap.QueryKeys {
+		c.queryKeys[key] = seg.segment()
+	}
+	c.tick = snap.Tick
+	c.shape++
+	c.observed = nil
+	c.timeouts.Store(0)
+	c.lastDecay = time.Time{}
+	return nil
+}
+
+// MarshalJSON encodes the full learned trie and configuration. Registered
+// custom parameter types and the Clock are not included.
+func (c *Classifier) MarshalJSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return json.Marshal(c.snapshot())
+}
+
+// UnmarshalJSON replaces the classifier's learned trie and configuration with
+// a snapshot produced by MarshalJSON. Config fields missing from the snapshot
+// keep their defaults, while the classifier's detectors, segment override
+// and Clock are kept. The Timeouts count, the patterns ClassifyObserve has
+// reported and the HalfLife decay clock start over, as after Reset; the
+// MaxNodes eviction order carries over.
+func (c *Classifier) UnmarshalJSON(data []byte) error {
+	snap := &classifierSnapshot{Config: DefaultConfig()}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.restore(snap)
+}
+
+// Save writes the classifier as JSON to w.
+func (c *Classifier) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c)
+}
+
+// Load reads a classifier previously written by Save.
+func Load(r io.Reader) (*Classifier, error) {
+	c := NewClassifier()
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GobEncode encodes the classifier in the same versioned snapshot form as
+// MarshalJSON, but in the more compact gob format.
+func (c *Classifier) GobEncode() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c.snapshot()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode replaces the classifier's learned trie and configuration with a
+// snapshot produced by GobEncode.
+func (c *Classifier) GobDecode(data []byte) error {
+	snap := &classifierSnapshot{Config: DefaultConfig()}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(snap); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.restore(snap)
+}
@@ -0,0 +1,171 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func trainedForSerialization() *Classifier {
+	c := NewClassifier(
+		WithCardinalityThreshold(0.6),
+		WithMaxValuesPerNode(5),
+		WithPruneHighCardinality(true),
+		WithClassifyQuery(true),
+	)
+	for i := 0; i < 20; i++ {
+		c.Learn([]string{
+			fmt.Sprintf("/api/v1/users/%08x-0000-4000-8000-%012x/profile", i, i),
+			fmt.Sprintf("/orders/%d/items?page=%d", 100000+i, i),
+			"/api/v1/health",
+		})
+	}
+	return c
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	original := trainedForSerialization()
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal() unexpected error: %v", err)
+	}
+
+	restored := NewClassifier()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() unexpected error: %v", err)
+	}
+
+	if restored.Stats() != original.Stats() {
+		t.Errorf("Stats() = %+v, want %+v", restored.Stats(), original.Stats())
+	}
+
+	// Clock is not serialized; compare the rest
+	rc, oc := *restored.config, *original.config
+	rc.Clock, oc.Clock = nil, nil
+	if !reflect.DeepEqual(rc, oc) {
+		t.Errorf("config = %+v, want %+v", rc, oc)
+	}
+
+	inputs := []string{
+		"/api/v1/users/000000ff-0000-4000-8000-0000000000ff/profile",
+		"/orders/200000/items?page=3",
+		"/api/v1/health",
+		"/unknown/path",
+	}
+	for _, url := range inputs {
+		want, _ := original.Classify(url)
+		got, _ := restored.Classify(url)
+		if got != want {
+			t.Errorf("Classify(%q) = %v after round-trip, want %v", url, got, want)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	original := trainedForSerialization()
+
+	var buf bytes.Buffer
+	if err := original.Save(&buf); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	want, _ := original.Classify("/orders/300000/items")
+	got, _ := loaded.Classify("/orders/300000/items")
+	if got != want {
+		t.Errorf("Classify() = %v after Load, want %v", got, want)
+	}
+}
+
+func TestUnmarshalKeepsClockAndEvictionOrder(t *testing.T) {
+	original := NewClassifier(WithMaxNodes(100))
+	original.Learn([]string{"/orders/1", "/users/1", "/orders/2"})
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal() unexpected error: %v", err)
+	}
+
+	fixed := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	restored := NewClassifier(WithClock(func() time.Time { return fixed }))
+	restored.timeouts.Store(3)
+	restored.observed = map[string]struct{}{"/stale": {}}
+	restored.lastDecay = fixed
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() unexpected error: %v", err)
+	}
+
+	if got := restored.config.Clock(); !got.Equal(fixed) {
+		t.Errorf("Clock() = %v after Unmarshal, want the receiver's clock at %v", got, fixed)
+	}
+	if restored.tick != original.tick {
+		t.Errorf("tick = %d, want %d", restored.tick, original.tick)
+	}
+	for _, key := range []string{"orders", "users"} {
+		if got, want := restored.root.children[key].lastAccess, original.root.children[key].lastAccess; got != want {
+			t.Errorf("%s lastAccess = %d, want %d", key, got, want)
+		}
+	}
+	if restored.Stats().Timeouts != 0 || restored.observed != nil || !restored.lastDecay.IsZero() {
+		t.Errorf("derived state not reset: timeouts %d, observed %v, lastDecay %v",
+			restored.Stats().Timeouts, restored.observed, restored.lastDecay)
+	}
+}
+
+func TestUnmarshalUnsupportedVersion(t *testing.T) {
+	c := NewClassifier()
+	err := json.Unmarshal([]byte(`{"version": 99, "root": {"value": ""}}`), c)
+	if err == nil {
+		t.Fatal("expected error for unsupported version, got nil")
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	original := trainedForSerialization()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(original); err != nil {
+		t.Fatalf("Encode() unexpected error: %v", err)
+	}
+
+	decoded := NewClassifier()
+	if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+
+	if decoded.Stats() != original.Stats() {
+		t.Errorf("Stats() = %+v, want %+v", decoded.Stats(), original.Stats())
+	}
+
+	// The decoded classifier must be fully functional under concurrency
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			decoded.Classify(fmt.Sprintf("/orders/%d/items", 500000+id))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		original.Classify(fmt.Sprintf("/orders/%d/items", 500000+i))
+	}
+	want, _ := original.Classify("/api/v1/users/00000aaa-0000-4000-8000-000000000aaa/profile")
+	got, _ := decoded.Classify("/api/v1/users/00000aaa-0000-4000-8000-000000000aaa/profile")
+	if got != want {
+		t.Errorf("Classify() = %v after gob round-trip, want %v", got, want)
+	}
+	if decoded.Stats() != original.Stats() {
+		t.Errorf("Stats() = %+v after concurrent use, want %+v", decoded.Stats(), original.Stats())
+	}
+}
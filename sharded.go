@@ -0,0 +1,746 @@
+package classifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"regexp"
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedClassifier spreads learning and classification over several
+// independent Classifiers, each behind its own lock, so that concurrent
+// Classify calls for different parts of the URL space don't serialize on a
+// single mutex. URLs are assigned to a shard by their first path segment:
+// /users/... and /orders/... may land on different shards, while every URL
+// under /users/ always lands on the same one, so patterns below the first
+// segment are learned exactly as by a single Classifier.
+//
+// The trade-off is that no shard sees every first segment, so variability
+// in the first segment itself (/{tenant}/...) is detected from each shard's
+// share of the values only. MinLearningCount also applies per shard.
+// Aggregating methods like Stats and Patterns combine the shards' results.
+//
+// Shard assignment depends only on the first segment, normalized as the
+// configuration says, and the number of shards, so a serialized ShardedClassifier loads into, and Merges with,
+// any other with the same number of shards.
+type ShardedClassifier struct {
+	shards       []*Classifier
+	patternCount atomic.Pointer[shardedPatternCount] // cached Stats.DistinctPatterns
+}
+
+// shardedPatternCount is a distinct pattern count cached for Stats, taken
+// with the shards in the states keys identify.
+type shardedPatternCount struct {
+	keys []patternCountKey
+	n    int
+}
+
+// NewShardedClassifier returns a ShardedClassifier with n shards configured
+// by opts. n <= 0 uses one shard per GOMAXPROCS.
+func NewShardedClassifier(n int, opts ...Option) *ShardedClassifier {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	s := &ShardedClassifier{shards: make([]*Classifier, n)}
+	for i := range s.shards {
+		s.shards[i] = NewClassifier(opts...)
+	}
+	return s
+}
+
+// Shards returns the number of shards.
+func (s *ShardedClassifier) Shards() int {
+	return len(s.shards)
+}
+
+// shardIndex returns the index of the shard responsible for url, chosen by
+// hashing its first path segment with 64-bit FNV-1a. The segment is taken
+// after the shards' own path normalization, ignoring any query, so URLs
+// that a Classifier learns as the same route always land on the same shard.
+func (s *ShardedClassifier) shardIndex(url string) int {
+	if len(s.shards) == 1 {
+		return 0
+	}
+	path, _ := splitQuery(url)
+	var first string
+	if parts := s.shards[0].splitURL(path); len(parts) > 0 {
+		first = parts[0]
+	}
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(first); i++ {
+		hash ^= uint64(first[i])
+		hash *= 1099511628211
+	}
+	return int(hash % uint64(len(s.shards)))
+}
+
+func (s *ShardedClassifier) shardFor(url string) *Classifier {
+	return s.shards[s.shardIndex(url)]
+}
+
+// partition groups urls by shard, keeping their order within each group.
+func (s *ShardedClassifier) partition(urls []string) [][]string {
+	groups := make([][]string, len(s.shards))
+	for _, url := range urls {
+		i := s.shardIndex(url)
+		groups[i] = append(groups[i], url)
+	}
+	return groups
+}
+
+// Config returns a copy of the configuration shared by every shard.
+func (s *ShardedClassifier) Config() Config {
+	return s.shards[0].Config()
+}
+
+// SetCardinalityThreshold updates the cardinality threshold of every shard.
+func (s *ShardedClassifier) SetCardinalityThreshold(threshold float64) error {
+	for _, shard := range s.shards {
+		if err := shard.SetCardinalityThreshold(threshold); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMinSamples updates the minimum sample count of every shard.
+func (s *ShardedClassifier) SetMinSamples(min int) error {
+	for _, shard := range s.shards {
+		if err := shard.SetMinSamples(min); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterParameterType registers a custom parameter type on every shard.
+func (s *ShardedClassifier) RegisterParameterType(name string, pattern *regexp.Regexp) {
+	for _, shard := range s.shards {
+		shard.RegisterParameterType(name, pattern)
+	}
+}
+
+// Learn learns urls, each into its shard.
+func (s *ShardedClassifier) Learn(urls []string) {
+	_ = s.LearnContext(context.Background(), urls)
+}
+
+// LearnContext is Learn that stops early when ctx is done, returning a
+// *LearnInterruptedError counting the URLs learned across all shards.
+func (s *ShardedClassifier) LearnContext(ctx context.Context, urls []string) error {
+	return s.learnMethodContext(ctx, "", urls)
+}
+
+// LearnMethod is Learn for requests with the given HTTP method (see
+// Classifier.LearnMethod).
+func (s *ShardedClassifier) LearnMethod(method string, urls []string) {
+	_ = s.learnMethodContext(context.Background(), method, urls)
+}
+
+func (s *ShardedClassifier) learnMethodContext(ctx context.Context, method string, urls []string) error {
+	learned := 0
+	for i, group := range s.partition(urls) {
+		if len(group) == 0 {
+			continue
+		}
+		if err := s.shards[i].learnMethodContext(ctx, method, group); err != nil {
+			var interrupted *LearnInterruptedError
+			if errors.As(err, &interrupted) {
+				return &LearnInterruptedError{Learned: learned + interrupted.Learned, Total: len(urls), Err: interrupted.Err}
+			}
+			return err
+		}
+		learned += len(group)
+	}
+	return nil
+}
+
+// LearnWeighted is Learn for pre-aggregated URL counts (see
+// Classifier.LearnWeighted).
+func (s *ShardedClassifier) LearnWeighted(entries []WeightedURL) {
+	groups := make([][]WeightedURL, len(s.shards))
+	for _, entry := range entries {
+		i := s.shardIndex(entry.URL)
+		groups[i] = append(groups[i], entry)
+	}
+	for i, group := range groups {
+		if len(group) > 0 {
+			s.shards[i].LearnWeighted(group)
+		}
+	}
+}
+
+// LearnReader is Classifier.LearnReader, learning each URL into its shard.
+func (s *ShardedClassifier) LearnReader(r io.Reader) (int, error) {
+	return s.LearnReaderContext(context.Background(), r)
+}
+
+// LearnReaderContext is Classifier.LearnReaderContext, learning each URL
+// into its shard under that shard's write lock only.
+func (s *ShardedClassifier) LearnReaderContext(ctx context.Context, r io.Reader) (int, error) {
+	cfg := s.Config()
+	return learnLines(ctx, r, cfg.maxLineLength(), func(url string) bool {
+		return s.shardFor(url).learnLine(url)
+	})
+}
+
+// Classify is Classifier.Classify on url's shard.
+func (s *ShardedClassifier) Classify(url string) (string, error) {
+	return s.shardFor(url).Classify(url)
+}
+
+// ClassifyWithConfidence is Classifier.ClassifyWithConfidence on url's shard.
+func (s *ShardedClassifier) ClassifyWithConfidence(url string) (pattern string, confidence float64, err error) {
+	return s.shardFor(url).ClassifyWithConfidence(url)
+}
+
+// ClassifyWithParams is Classifier.ClassifyWithParams on url's shard.
+func (s *ShardedClassifier) ClassifyWithParams(url string) (pattern string, params map[string]string, err error) {
+	return s.shardFor(url).ClassifyWithParams(url)
+}
+
+// ClassifyObserve is Classifier.ClassifyObserve on url's shard. Since a
+// pattern is only ever produced by one shard, isNew holds across shards.
+func (s *ShardedClassifier) ClassifyObserve(url string) (pattern string, isNew bool, err error) {
+	return s.shardFor(url).ClassifyObserve(url)
+}
+
+// ClassifyOnly is Classifier.ClassifyOnly on url's shard.
+func (s *ShardedClassifier) ClassifyOnly(url string) (string, error) {
+	return s.shardFor(url).ClassifyOnly(url)
+}
+
+// DryRunClassify is Classifier.DryRunClassify on url's shard.
+func (s *ShardedClassifier) DryRunClassify(url string) (string, error) {
+	return s.shardFor(url).DryRunClassify(url)
+}
+
+// ClassifyMethod is Classifier.ClassifyMethod on url's shard.
+func (s *ShardedClassifier) ClassifyMethod(method, url string) (string, error) {
+	return s.shardFor(url).ClassifyMethod(method, url)
+}
+
+// ClassifyURL is Classifier.ClassifyURL on url's shard. Hosts are learned
+// per shard.
+func (s *ShardedClassifier) ClassifyURL(rawURL string) (string, error) {
+	return s.shardFor(rawURL).ClassifyURL(rawURL)
+}
+
+// Explain is Classifier.Explain on url's shard.
+func (s *ShardedClassifier) Explain(url string) ([]SegmentDecision, error) {
+	return s.shardFor(url).Explain(url)
+}
+
+// Forget is Classifier.Forget on url's shard.
+func (s *ShardedClassifier) Forget(url string) error {
+	return s.shardFor(url).Forget(url)
+}
+
+// Reset clears the learned state of every shard.
+func (s *ShardedClassifier) Reset() {
+	for _, shard := range s.shards {
+		shard.Reset()
+	}
+}
+
+// Freeze applies Classifier.Freeze to every shard.
+func (s *ShardedClassifier) Freeze() {
+	for _, shard := range s.shards {
+		shard.Freeze()
+	}
+}
+
+// Unfreeze applies Classifier.Unfreeze to every shard.
+func (s *ShardedClassifier) Unfreeze() {
+	for _, shard := range s.shards {
+		shard.Unfreeze()
+	}
+}
+
+// Frozen reports whether the shards are frozen (see Classifier.Freeze).
+func (s *ShardedClassifier) Frozen() bool {
+	return s.shards[0].Frozen()
+}
+
+// Decay applies Classifier.Decay to every shard.
+func (s *ShardedClassifier) Decay(factor float64) error {
+	for _, shard := range s.shards {
+		if err := shard.Decay(factor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune applies Classifier.Prune to every shard and returns the total
+// number of nodes collapsed.
+func (s *ShardedClassifier) Prune() int {
+	collapsed := 0
+	for _, shard := range s.shards {
+		collapsed += shard.Prune()
+	}
+	return collapsed
+}
+
+// Stats sums the statistics of every shard; MaxDepth is the deepest shard's
+// and DistinctPatterns counts patterns shared by shards once, recounted as
+// by Classifier.Stats. Each shard has its own root, which NodeCount
+// includes.
+func (s *ShardedClassifier) Stats() Stats {
+	var total Stats
+	for _, shard := range s.shards {
+		stats := shard.Stats()
+		total.LearnedCount += stats.LearnedCount
+		total.NodeCount += stats.NodeCount
+		total.MaxDepth = max(total.MaxDepth, stats.MaxDepth)
+		total.MemoryEstimate += stats.MemoryEstimate
+		total.UniqueValues += stats.UniqueValues
+		total.PrunedNodes += stats.PrunedNodes
+		total.CollapsedNodes += stats.CollapsedNodes
+		total.Timeouts += stats.Timeouts
+	}
+	total.DistinctPatterns = s.cachedDistinctPatterns()
+	return total
+}
+
+// cachedDistinctPatterns is DistinctPatternCount for Stats, recounted only
+// once a shard's key changes, as Classifier.cachedDistinctPatterns.
+func (s *ShardedClassifier) cachedDistinctPatterns() int {
+	keys := make([]patternCountKey, len(s.shards))
+	for i, shard := range s.shards {
+		shard.mu.RLock()
+		keys[i] = shard.patternCountKey()
+		shard.mu.RUnlock()
+	}
+	if cached := s.patternCount.Load(); cached != nil && slices.Equal(cached.keys, keys) {
+		return cached.n
+	}
+	n := s.DistinctPatternCount()
+	s.patternCount.Store(&shardedPatternCount{keys: keys, n: n})
+	return n
+}
+
+// DistinctPatternCount returns the number of distinct patterns across all
+// shards, equal to len(Patterns()).
+func (s *ShardedClassifier) DistinctPatternCount() int {
+	count := 0
+	for range s.PatternSeq() {
+		count++
+	}
+	return count
+}
+
+// ReportMetrics is Classifier.ReportMetrics with the summed Stats of every
+// shard.
+func (s *ShardedClassifier) ReportMetrics(ctx context.Context, sink MetricsSink, interval time.Duration) error {
+	return reportMetrics(ctx, s.Stats, sink, interval)
+}
+
+// LearnedCount returns the number of URLs learned across all shards.
+func (s *ShardedClassifier) LearnedCount() int {
+	count := 0
+	for _, shard := range s.shards {
+		count += shard.LearnedCount()
+	}
+	return count
+}
+
+// NodeCount returns the number of trie nodes across all shards.
+func (s *ShardedClassifier) NodeCount() int {
+	count := 0
+	for _, shard := range s.shards {
+		count += shard.NodeCount()
+	}
+	return count
+}
+
+// MemoryUsage returns the estimated memory usage of all shards.
+func (s *ShardedClassifier) MemoryUsage() int64 {
+	var usage int64
+	for _, shard := range s.shards {
+		usage += shard.MemoryUsage()
+	}
+	return usage
+}
+
+// Patterns returns the distinct patterns recognized by any shard, sorted.
+func (s *ShardedClassifier) Patterns() []string {
+	return slices.Sorted(s.PatternSeq())
+}
+
+// PatternsWithPrefix returns the distinct patterns of every shard at or
+// below prefix, sorted (see Classifier.PatternsWithPrefix).
+func (s *ShardedClassifier) PatternsWithPrefix(prefix string) []string {
+	patterns := []string{}
+	for _, shard := range s.shards {
+		patterns = append(patterns, shard.PatternsWithPrefix(prefix)...)
+	}
+	slices.Sort(patterns)
+	return slices.Compact(patterns)
+}
+
+// PatternSeq yields the distinct patterns of every shard in turn, like
+// Classifier.PatternSeq. Each shard's read lock is held while its patterns
+// are yielded.
+func (s *ShardedClassifier) PatternSeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		seen := make(map[string]struct{})
+		for _, shard := range s.shards {
+			for pattern := range shard.PatternSeq() {
+				if _, ok := seen[pattern]; ok {
+					continue
+				}
+				seen[pattern] = struct{}{}
+				if !yield(pattern) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// PatternFor is Classifier.PatternFor on url's shard. hits counts that
+// shard only, which holds every URL with the same first segment.
+func (s *ShardedClassifier) PatternFor(url string) (pattern string, hits int, err error) {
+	return s.shardFor(url).PatternFor(url)
+}
+
+// PatternCounts sums the pattern counts of every shard.
+func (s *ShardedClassifier) PatternCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, shard := range s.shards {
+		for pattern, count := range shard.PatternCounts() {
+			counts[pattern] += count
+		}
+	}
+	return counts
+}
+
+// PatternLastSeen combines the PatternLastSeen maps of every shard, keeping
+// the latest time of a pattern learned by several shards.
+func (s *ShardedClassifier) PatternLastSeen() map[string]time.Time {
+	lastSeen := make(map[string]time.Time)
+	for _, shard := range s.shards {
+		for pattern, seen := range shard.PatternLastSeen() {
+			if seen.After(lastSeen[pattern]) {
+				lastSeen[pattern] = seen
+			}
+		}
+	}
+	return lastSeen
+}
+
+// PatternRegexp is Classifier.PatternRegexp, which depends only on the
+// configuration and registered types that every shard shares.
+func (s *ShardedClassifier) PatternRegexp(pattern string) (*regexp.Regexp, error) {
+	return s.shards[0].PatternRegexp(pattern)
+}
+
+// RouteTable combines the route tables of every shard. A pattern learned by
+// several shards is listed once, with their counts summed and the earliest
+// FirstSeen and latest LastSeen.
+func (s *ShardedClassifier) RouteTable() []RouteEntry {
+	table := []RouteEntry{}
+	index := make(map[string]int)
+	for _, shard := range s.shards {
+		for _, entry := range shard.RouteTable() {
+			i, exists := index[entry.Pattern]
+			if !exists {
+				index[entry.Pattern] = len(table)
+				table = append(table, entry)
+				continue
+			}
+			merged := &table[i]
+			merged.Count += entry.Count
+			if entry.FirstSeen.Before(merged.FirstSeen) {
+				merged.FirstSeen = entry.FirstSeen
+			}
+			if entry.LastSeen.After(merged.LastSeen) {
+				merged.LastSeen = entry.LastSeen
+			}
+		}
+	}
+	sortRouteTable(table)
+	return table
+}
+
+// ToRoutes is Classifier.ToRoutes over the routes of every shard, ordered
+// together. Each shard's read lock is held while its routes are collected.
+func (s *ShardedClassifier) ToRoutes(style RouteStyle) []string {
+	seen := make(map[string]bool)
+	var routes []route
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		routes = shard.routes(style, seen, routes)
+		shard.mu.RUnlock()
+	}
+	return sortRoutes(routes)
+}
+
+// ToOpenAPIPaths combines the OpenAPI path items of every shard, joining
+// the operations of a path template learned by several shards.
+func (s *ShardedClassifier) ToOpenAPIPaths() map[string]OpenAPIPathItem {
+	paths := make(map[string]OpenAPIPathItem)
+	for _, shard := range s.shards {
+		for template, item := range shard.ToOpenAPIPaths() {
+			merged := paths[template]
+			merged.merge(item)
+			paths[template] = merged
+		}
+	}
+	return paths
+}
+
+// ExportStats is Classifier.ExportStats over every shard, combining the
+// counts and distinct paths of a pattern learned by several shards. Each
+// shard's read lock is held while its patterns are collected.
+func (s *ShardedClassifier) ExportStats(w io.Writer) error {
+	byPattern := make(map[string]*patternStats)
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		shard.collectPatternStats(byPattern)
+		shard.mu.RUnlock()
+	}
+	return writePatternStats(w, byPattern)
+}
+
+// CardinalityAt is Classifier.CardinalityAt on prefix's shard. The root
+// prefix describes the first segment, whose values are spread over the
+// shards, so for it the counts of every shard are combined.
+func (s *ShardedClassifier) CardinalityAt(prefix string) (float64, int, error) {
+	first := s.shards[0]
+	first.mu.RLock()
+	root := len(first.splitURL(prefix)) == 0
+	first.mu.RUnlock()
+	if !root {
+		return s.shardFor(prefix).CardinalityAt(prefix)
+	}
+
+	unique, total := 0, 0
+	for _, shard := range s.shards {
+		shard.mu.RLock()
+		u, t, err := shard.cardinalityCounts(prefix)
+		shard.mu.RUnlock()
+		if err != nil {
+			return 0, 0, err
+		}
+		unique += u
+		total += t
+	}
+	if total == 0 {
+		return 0, 0, fmt.Errorf("nothing has been learned below prefix %q", prefix)
+	}
+	return float64(unique) / float64(total), unique, nil
+}
+
+// TopCardinalityNodes returns the n most variable positions across all
+// shards. Positions below a first segment are learned by a single shard,
+// except below a first segment collapsed into a wildcard, where each shard
+// lists its own.
+func (s *ShardedClassifier) TopCardinalityNodes(n int) []NodeInfo {
+	if n <= 0 {
+		return nil
+	}
+	var nodes []NodeInfo
+	for _, shard := range s.shards {
+		nodes = append(nodes, shard.TopCardinalityNodes(n)...)
+	}
+	return topNodes(nodes, n)
+}
+
+// DepthDistribution sums the depth distributions of every shard. Each shard
+// has its own roots, which depth 0 includes.
+func (s *ShardedClassifier) DepthDistribution() map[int]int {
+	histogram := make(map[int]int)
+	for _, shard := range s.shards {
+		for depth, count := range shard.DepthDistribution() {
+			histogram[depth] += count
+		}
+	}
+	return histogram
+}
+
+// Walk is Classifier.Walk over every shard in turn, so the roots are
+// visited once per shard. Each shard's read lock is held while it is
+// walked.
+func (s *ShardedClassifier) Walk(fn func(path string, info SegmentInfo) bool) {
+	for _, shard := range s.shards {
+		shard.Walk(fn)
+	}
+}
+
+// ToDOT is Classifier.ToDOT with each shard drawn as a cluster labeled with
+// its index; maxNodes applies per shard. Each shard is rendered under its
+// own read lock, and the graph is written after all are released.
+func (s *ShardedClassifier) ToDOT(w io.Writer, maxNodes int) error {
+	var buf bytes.Buffer
+	writeDOTHeader(&buf)
+	for i, shard := range s.shards {
+		fmt.Fprintf(&buf, "\tsubgraph cluster_%d {\n\t\tlabel=\"shard %d\";\n", i, i)
+		shard.mu.RLock()
+		shard.writeDOT(&buf, maxNodes, fmt.Sprintf("s%dn", i))
+		shard.mu.RUnlock()
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Snapshot returns an independent copy of every shard (see
+// Classifier.Snapshot). Shards are copied one at a time, so learning that
+// runs concurrently may be reflected in some shards and not in others.
+func (s *ShardedClassifier) Snapshot() *ShardedClassifier {
+	snap := &ShardedClassifier{shards: make([]*Classifier, len(s.shards))}
+	for i, shard := range s.shards {
+		snap.shards[i] = shard.Snapshot()
+	}
+	return snap
+}
+
+// Merge folds other into s shard by shard (see Classifier.Merge). Both must
+// have the same number of shards, so that a URL's shard is the same in each.
+func (s *ShardedClassifier) Merge(other *ShardedClassifier) error {
+	if len(other.shards) != len(s.shards) {
+		return fmt.Errorf("cannot merge %d shards into %d", len(other.shards), len(s.shards))
+	}
+	for i, shard := range s.shards {
+		shard.Merge(other.shards[i])
+	}
+	return nil
+}
+
+// shardedSnapshot is the serializable form of a ShardedClassifier: every
+// shard in its own serialized form.
+type shardedSnapshot[T any] struct {
+	Version int `json:"version"`
+	Shards  []T `json:"shards"`
+}
+
+// MarshalJSON encodes every shard as Classifier.MarshalJSON does, one at a
+// time.
+func (s *ShardedClassifier) MarshalJSON() ([]byte, error) {
+	snap := shardedSnapshot[json.RawMessage]{Version: snapshotVersion, Shards: make([]json.RawMessage, len(s.shards))}
+	for i, shard := range s.shards {
+		data, err := shard.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		snap.Shards[i] = data
+	}
+	return json.Marshal(snap)
+}
+
+// UnmarshalJSON replaces the learned trie and configuration of every shard
+// with a snapshot produced by MarshalJSON. A zero ShardedClassifier takes
+// the snapshot's number of shards; otherwise the numbers must match. No
+// shard is changed if any fails to decode.
+func (s *ShardedClassifier) UnmarshalJSON(data []byte) error {
+	var snap shardedSnapshot[json.RawMessage]
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	shards := make([]*classifierSnapshot, len(snap.Shards))
+	for i, raw := range snap.Shards {
+		shards[i] = &classifierSnapshot{Config: DefaultConfig()}
+		if err := json.Unmarshal(raw, shards[i]); err != nil {
+			return err
+		}
+	}
+	return s.restore(snap.Version, shards)
+}
+
+// Save writes every shard as JSON to w.
+func (s *ShardedClassifier) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// LoadSharded reads a ShardedClassifier previously written by Save, with
+// the number of shards it was saved with.
+func LoadSharded(r io.Reader) (*ShardedClassifier, error) {
+	s := &ShardedClassifier{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GobEncode encodes every shard as Classifier.GobEncode does, one at a
+// time.
+func (s *ShardedClassifier) GobEncode() ([]byte, error) {
+	snap := shardedSnapshot[[]byte]{Version: snapshotVersion, Shards: make([][]byte, len(s.shards))}
+	for i, shard := range s.shards {
+		data, err := shard.GobEncode()
+		if err != nil {
+			return nil, err
+		}
+		snap.Shards[i] = data
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode is UnmarshalJSON for a snapshot produced by GobEncode.
+func (s *ShardedClassifier) GobDecode(data []byte) error {
+	var snap shardedSnapshot[[]byte]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	shards := make([]*classifierSnapshot, len(snap.Shards))
+	for i, raw := range snap.Shards {
+		shards[i] = &classifierSnapshot{Config: DefaultConfig()}
+		if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(shards[i]); err != nil {
+			return err
+		}
+	}
+	return s.restore(snap.Version, shards)
+}
+
+// restore replaces the state of every shard with snaps, after checking
+// that all of them can be restored.
+func (s *ShardedClassifier) restore(version int, snaps []*classifierSnapshot) error {
+	if version < 1 || version > snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("snapshot has no shards")
+	}
+	for _, snap := range snaps {
+		if err := snap.validate(); err != nil {
+			return err
+		}
+	}
+	if s.shards == nil {
+		s.shards = make([]*Classifier, len(snaps))
+		for i := range s.shards {
+			s.shards[i] = NewClassifier()
+		}
+	}
+	if len(snaps) != len(s.shards) {
+		return fmt.Errorf("snapshot has %d shards, want %d", len(snaps), len(s.shards))
+	}
+
+	for i, shard := range s.shards {
+		shard.mu.Lock()
+		err := shard.restore(snaps[i])
+		shard.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// multiPrefixCorpus returns URLs spread over prefixes first segments, each
+// with numeric IDs below it.
+func multiPrefixCorpus(prefixes, perPrefix int) []string {
+	urls := make([]string, 0, prefixes*perPrefix)
+	for i := 0; i < perPrefix; i++ {
+		for p := 0; p < prefixes; p++ {
+			urls = append(urls, fmt.Sprintf("/svc%d/users/%d/profile", p, 100000+i))
+		}
+	}
+	return urls
+}
+
+func TestShardedClassifier(t *testing.T) {
+	urls := multiPrefixCorpus(16, 10)
+	single := NewClassifier(WithImmutableClassify(true))
+	single.Learn(urls)
+	sharded := NewShardedClassifier(4, WithImmutableClassify(true))
+	sharded.Learn(urls)
+
+	if got := sharded.Shards(); got != 4 {
+		t.Errorf("Shards() = %d, want 4", got)
+	}
+	if got, want := sharded.Patterns(), single.Patterns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Patterns() = %v, want %v", got, want)
+	}
+	if got, want := sharded.PatternsWithPrefix("/svc3"), single.PatternsWithPrefix("/svc3"); !reflect.DeepEqual(got, want) {
+		t.Errorf("PatternsWithPrefix() = %v, want %v", got, want)
+	}
+	if got, want := sharded.PatternCounts(), single.PatternCounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PatternCounts() = %v, want %v", got, want)
+	}
+	for _, url := range []string{"/svc3/users/999999/profile", "/svc12/users/123456/profile"} {
+		got, err := sharded.Classify(url)
+		if err != nil {
+			t.Fatalf("Classify(%q) unexpected error: %v", url, err)
+		}
+		if want, _ := single.Classify(url); got != want {
+			t.Errorf("Classify(%q) = %v, want %v", url, got, want)
+		}
+	}
+
+	stats := sharded.Stats()
+	if stats.LearnedCount != len(urls) || sharded.LearnedCount() != len(urls) {
+		t.Errorf("LearnedCount = %d, %d, want %d", stats.LearnedCount, sharded.LearnedCount(), len(urls))
+	}
+	if want := single.Stats().MaxDepth; stats.MaxDepth != want {
+		t.Errorf("Stats().MaxDepth = %d, want %d", stats.MaxDepth, want)
+	}
+	if stats.NodeCount != sharded.NodeCount() {
+		t.Errorf("Stats().NodeCount = %d, want NodeCount() %d", stats.NodeCount, sharded.NodeCount())
+	}
+
+	sharded.Reset()
+	if got := sharded.LearnedCount(); got != 0 {
+		t.Errorf("LearnedCount() after Reset = %d, want 0", got)
+	}
+}
+
+func TestShardedClassifier_SamePrefixSameShard(t *testing.T) {
+	sharded := NewShardedClassifier(8)
+	want := sharded.shardIndex("/users/1")
+	for _, url := range []string{"/users", "/users/2/profile", "/users?page=2", "https://example.com/users/3"} {
+		if got := sharded.shardIndex(url); got != want {
+			t.Errorf("shardIndex(%q) = %d, want %d", url, got, want)
+		}
+	}
+}
+
+func TestShardedClassifier_NormalizedSameShard(t *testing.T) {
+	sharded := NewShardedClassifier(8,
+		WithCollapseEmptySegments(true),
+		WithStripMatrixParams(true),
+		WithDecodeSegments(true),
+	)
+	want := sharded.shardIndex("/users/1")
+	for _, url := range []string{"//users/1", "/users;jsessionid=1/2", "/%75sers/3", "https://example.com//users;v=2/4"} {
+		if got := sharded.shardIndex(url); got != want {
+			t.Errorf("shardIndex(%q) = %d, want %d", url, got, want)
+		}
+	}
+
+	custom := NewShardedClassifier(8, WithSeparator("."))
+	if got, want := custom.shardIndex(".users.1"), custom.shardIndex(".users.2.profile"); got != want {
+		t.Errorf("shardIndex(.users.1) = %d, want %d like .users.2.profile", got, want)
+	}
+}
+
+func TestShardedClassifier_Exports(t *testing.T) {
+	urls := multiPrefixCorpus(16, 10)
+	single := NewClassifier(WithImmutableClassify(true))
+	single.Learn(urls)
+	sharded := NewShardedClassifier(4, WithImmutableClassify(true))
+	sharded.Learn(urls)
+
+	if got, want := sharded.ToRoutes(RouteStyleChi), single.ToRoutes(RouteStyleChi); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToRoutes() = %v, want %v", got, want)
+	}
+	if got, want := sharded.ToOpenAPIPaths(), single.ToOpenAPIPaths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToOpenAPIPaths() = %v, want %v", got, want)
+	}
+	if got, want := sharded.TopCardinalityNodes(5), single.TopCardinalityNodes(5); !reflect.DeepEqual(got, want) {
+		t.Errorf("TopCardinalityNodes(5) = %v, want %v", got, want)
+	}
+
+	gotTable, wantTable := sharded.RouteTable(), single.RouteTable()
+	if len(gotTable) != len(wantTable) {
+		t.Fatalf("RouteTable() has %d entries, want %d", len(gotTable), len(wantTable))
+	}
+	for i := range gotTable {
+		if gotTable[i].Pattern != wantTable[i].Pattern || gotTable[i].Count != wantTable[i].Count {
+			t.Errorf("RouteTable()[%d] = %s (%d), want %s (%d)", i, gotTable[i].Pattern, gotTable[i].Count, wantTable[i].Pattern, wantTable[i].Count)
+		}
+	}
+	if got := sharded.PatternLastSeen(); len(got) != len(wantTable) {
+		t.Errorf("PatternLastSeen() has %d patterns, want %d", len(got), len(wantTable))
+	}
+
+	var gotStats, wantStats bytes.Buffer
+	if err := sharded.ExportStats(&gotStats); err != nil {
+		t.Fatalf("ExportStats() unexpected error: %v", err)
+	}
+	single.ExportStats(&wantStats)
+	if gotStats.String() != wantStats.String() {
+		t.Errorf("ExportStats() = %s, want %s", gotStats.String(), wantStats.String())
+	}
+
+	for _, prefix := range []string{"/", "/svc3/users"} {
+		gotRatio, gotUnique, err := sharded.CardinalityAt(prefix)
+		if err != nil {
+			t.Fatalf("CardinalityAt(%q) unexpected error: %v", prefix, err)
+		}
+		if wantRatio, wantUnique, _ := single.CardinalityAt(prefix); gotRatio != wantRatio || gotUnique != wantUnique {
+			t.Errorf("CardinalityAt(%q) = %v, %d, want %v, %d", prefix, gotRatio, gotUnique, wantRatio, wantUnique)
+		}
+	}
+
+	// Every shard has its own root
+	gotDepths, wantDepths := sharded.DepthDistribution(), single.DepthDistribution()
+	wantDepths[0] = 4
+	if !reflect.DeepEqual(gotDepths, wantDepths) {
+		t.Errorf("DepthDistribution() = %v, want %v", gotDepths, wantDepths)
+	}
+	walked := 0
+	sharded.Walk(func(path string, info SegmentInfo) bool {
+		walked++
+		return true
+	})
+	if walked != sharded.NodeCount() {
+		t.Errorf("Walk() visited %d nodes, want %d", walked, sharded.NodeCount())
+	}
+
+	re, err := sharded.PatternRegexp("/svc3/users/{id}/profile")
+	if err != nil || !re.MatchString("/svc3/users/42/profile") {
+		t.Errorf("PatternRegexp() = %v, %v, want a match for /svc3/users/42/profile", re, err)
+	}
+
+	var dot bytes.Buffer
+	if err := sharded.ToDOT(&dot, 0); err != nil {
+		t.Fatalf("ToDOT() unexpected error: %v", err)
+	}
+	for _, want := range []string{"subgraph cluster_3 {", "s0n0 [label=\"/\"];", "s3n0 -> s3n1;"} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("ToDOT() output missing %q:\n%s", want, dot.String())
+		}
+	}
+}
+
+func TestShardedClassifier_SnapshotMergeSerialize(t *testing.T) {
+	urls := multiPrefixCorpus(16, 10)
+	sharded := NewShardedClassifier(4)
+	if n, err := sharded.LearnReader(strings.NewReader(strings.Join(urls, "\n"))); err != nil || n != len(urls) {
+		t.Fatalf("LearnReader() = %d, %v, want %d, nil", n, err, len(urls))
+	}
+	want := sharded.Patterns()
+
+	snap := sharded.Snapshot()
+	sharded.Learn([]string{"/extra/path"})
+	if got := snap.LearnedCount(); got != len(urls) {
+		t.Errorf("Snapshot().LearnedCount() = %d after learning into the original, want %d", got, len(urls))
+	}
+
+	var saved bytes.Buffer
+	if err := snap.Save(&saved); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	loaded, err := LoadSharded(&saved)
+	if err != nil {
+		t.Fatalf("LoadSharded() unexpected error: %v", err)
+	}
+	if loaded.Shards() != 4 || !reflect.DeepEqual(loaded.Patterns(), want) {
+		t.Errorf("LoadSharded() = %d shards with %v, want 4 with %v", loaded.Shards(), loaded.Patterns(), want)
+	}
+	if got, _ := loaded.ClassifyOnly("/svc5/users/100005/profile"); got != "/svc5/users/{id}/profile" {
+		t.Errorf("loaded ClassifyOnly() = %q, want /svc5/users/{id}/profile", got)
+	}
+
+	data, err := snap.GobEncode()
+	if err != nil {
+		t.Fatalf("GobEncode() unexpected error: %v", err)
+	}
+	decoded := NewShardedClassifier(4)
+	if err := decoded.GobDecode(data); err != nil {
+		t.Fatalf("GobDecode() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Patterns(), want) {
+		t.Errorf("GobDecode() patterns = %v, want %v", decoded.Patterns(), want)
+	}
+
+	encoded, _ := json.Marshal(snap)
+	if err := json.Unmarshal(encoded, NewShardedClassifier(2)); err == nil {
+		t.Error("UnmarshalJSON() into 2 shards: expected error for a 4-shard snapshot")
+	}
+
+	merged := NewShardedClassifier(4)
+	if err := merged.Merge(snap); err != nil {
+		t.Fatalf("Merge() unexpected error: %v", err)
+	}
+	if err := merged.Merge(loaded); err != nil {
+		t.Fatalf("Merge() unexpected error: %v", err)
+	}
+	if got := merged.LearnedCount(); got != 2*len(urls) {
+		t.Errorf("LearnedCount() after Merge = %d, want %d", got, 2*len(urls))
+	}
+	if err := merged.Merge(NewShardedClassifier(2)); err == nil {
+		t.Error("Merge() of 2 shards into 4: expected error")
+	}
+}
+
+func benchmarkClassifyParallel(b *testing.B, classify func(string) (string, error)) {
+	corpus := multiPrefixCorpus(64, 50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			classify(corpus[i%len(corpus)])
+			i++
+		}
+	})
+}
+
+func BenchmarkClassifyParallel(b *testing.B) {
+	b.Run("single", func(b *testing.B) {
+		c := NewClassifier()
+		c.Learn(multiPrefixCorpus(64, 50))
+		benchmarkClassifyParallel(b, c.Classify)
+	})
+	b.Run("sharded", func(b *testing.B) {
+		c := NewShardedClassifier(0)
+		c.Learn(multiPrefixCorpus(64, 50))
+		benchmarkClassifyParallel(b, c.Classify)
+	})
+}
@@ -0,0 +1,79 @@
+package classifier
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler is a slog.Handler that rewrites URL attributes to their
+// classified pattern before passing records on, so logs carry
+// /users/{id}/profile instead of one distinct value per user. Attributes are
+// matched by key at any depth, including inside groups; only string values
+// are rewritten. Values are classified with Classify, so logged URLs are
+// learned too, and are kept as-is while the classifier is short of
+// MinLearningCount.
+type LogHandler struct {
+	c    *Classifier
+	next slog.Handler
+	keys map[string]bool
+}
+
+// NewLogHandler returns a LogHandler passing records to next, rewriting
+// attributes with the given keys, or "url" and "path" if none are given.
+func NewLogHandler(c *Classifier, next slog.Handler, keys ...string) *LogHandler {
+	if len(keys) == 0 {
+		keys = []string{"url", "path"}
+	}
+	h := &LogHandler{c: c, next: next, keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		h.keys[key] = true
+	}
+	return h
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	rewritten := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		rewritten.AddAttrs(h.rewrite(attr))
+		return true
+	})
+	return h.next.Handle(ctx, rewritten)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	rewritten := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		rewritten[i] = h.rewrite(attr)
+	}
+	return &LogHandler{c: h.c, next: h.next.WithAttrs(rewritten), keys: h.keys}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{c: h.c, next: h.next.WithGroup(name), keys: h.keys}
+}
+
+// rewrite returns attr with its value classified if its key matches, and
+// the attributes of groups rewritten in turn.
+func (h *LogHandler) rewrite(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		rewritten := make([]slog.Attr, len(group))
+		for i, member := range group {
+			rewritten[i] = h.rewrite(member)
+		}
+		attr.Value = slog.GroupValue(rewritten...)
+	case slog.KindString:
+		if h.keys[attr.Key] {
+			if pattern, err := h.c.Classify(attr.Value.String()); err == nil && pattern != "" {
+				attr.Value = slog.StringValue(pattern)
+			}
+		}
+	}
+	return attr
+}
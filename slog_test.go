@@ -0,0 +1,71 @@
+package classifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{
+		"/users/123456/profile",
+		"/users/789012/profile",
+		"/users/345678/profile",
+	})
+
+	t.Run("default keys and groups", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(NewLogHandler(c, slog.NewJSONHandler(&buf, nil)))
+		logger.With("path", "/users/111111/profile").Info("request",
+			"url", "/users/555555/profile",
+			"user", "/users/555555/profile",
+			slog.Group("http", "path", "/users/999999/profile", "status", 200),
+		)
+
+		var entry struct {
+			Path string `json:"path"`
+			URL  string `json:"url"`
+			User string `json:"user"`
+			HTTP struct {
+				Path   string `json:"path"`
+				Status int    `json:"status"`
+			} `json:"http"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		if entry.Path != "/users/{id}/profile" {
+			t.Errorf("path = %v, want /users/{id}/profile", entry.Path)
+		}
+		if entry.URL != "/users/{id}/profile" {
+			t.Errorf("url = %v, want /users/{id}/profile", entry.URL)
+		}
+		if entry.User != "/users/555555/profile" {
+			t.Errorf("user = %v, want it unchanged", entry.User)
+		}
+		if entry.HTTP.Path != "/users/{id}/profile" || entry.HTTP.Status != 200 {
+			t.Errorf("http = %+v, want path /users/{id}/profile and status 200", entry.HTTP)
+		}
+	})
+
+	t.Run("custom key under WithGroup", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(NewLogHandler(c, slog.NewJSONHandler(&buf, nil), "route"))
+		logger.WithGroup("req").Info("request", "route", "/users/555555/profile", "url", "/users/555555/profile")
+
+		var entry struct {
+			Req map[string]string `json:"req"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		if got := entry.Req["route"]; got != "/users/{id}/profile" {
+			t.Errorf("req.route = %v, want /users/{id}/profile", got)
+		}
+		if got := entry.Req["url"]; got != "/users/555555/profile" {
+			t.Errorf("req.url = %v, want it unchanged", got)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package classifier
+
+// SegmentInfo is a read-only view of a trie node passed to Walk.
+type SegmentInfo struct {
+	Value       string   // Segment text; * for a wildcard, empty for a root
+	TotalCount  int      // Times the node was traversed while learning
+	Cardinality float64  // Unique values per traversal; 1.0 if pruned
+	IsEnd       bool     // A learned URL ends here
+	Collapsed   bool     // Children were collapsed into a wildcard
+	Pruned      bool     // Per-value data was discarded after confirming high cardinality
+	ChildCount  int      // Number of direct children
+	Samples     []string // Recent raw values of a wildcard, oldest first (see WithSampleRetention)
+}
+
+// Walk traverses every trie depth-first, children in key order, calling fn
+// with each node's path and a snapshot of its state. Paths are the learned
+// segments joined by slashes, so wildcard nodes appear as *; nodes learned
+// with LearnMethod are prefixed by their method, e.g. "GET /users". Returning
+// false from fn skips the node's subtree.
+//
+// The read lock is held for the whole traversal, so fn must not call back
+// into the classifier: learning deadlocks immediately, and even reads can
+// deadlock once a writer is waiting for the lock.
+func (c *Classifier) Walk(fn func(path string, info SegmentInfo) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	c.walkTrie(func(path string, node *Segment, depth int) bool {
+		return fn(path, SegmentInfo{
+			Value:       node.value,
+			TotalCount:  node.totalCount.load(),
+			Cardinality: node.Cardinality(),
+			IsEnd:       node.isEnd,
+			Collapsed:   node.collapsed,
+			Pruned:      node.pruned,
+			ChildCount:  len(node.children),
+			Samples:     node.recentSamples(),
+		})
+	})
+}
@@ -0,0 +1,44 @@
+package classifier
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{
+		"/api/users",
+		"/api/orders",
+		"/health",
+	})
+	c.LearnMethod("POST", []string{"/api/users"})
+
+	var paths []string
+	c.Walk(func(path string, info SegmentInfo) bool {
+		paths = append(paths, path)
+		if path == "/api/users" && (!info.IsEnd || info.Value != "users" || info.ChildCount != 0) {
+			t.Errorf("Walk() info for %s = %+v", path, info)
+		}
+		return true
+	})
+	expected := []string{"/", "/api", "/api/orders", "/api/users", "/health", "POST /", "POST /api", "POST /api/users"}
+	if fmt.Sprint(paths) != fmt.Sprint(expected) {
+		t.Errorf("Walk() paths = %v, want %v", paths, expected)
+	}
+}
+
+func TestWalkSkipsSubtree(t *testing.T) {
+	c := NewClassifier(WithImmutableClassify(true))
+	c.Learn([]string{"/api/users", "/api/orders", "/health"})
+
+	var paths []string
+	c.Walk(func(path string, info SegmentInfo) bool {
+		paths = append(paths, path)
+		return path != "/api"
+	})
+	expected := []string{"/", "/api", "/health"}
+	if fmt.Sprint(paths) != fmt.Sprint(expected) {
+		t.Errorf("Walk() paths = %v, want %v", paths, expected)
+	}
+}